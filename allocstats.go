@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"reflect"
+	"sync/atomic"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// AllocStats records the interpreted allocations when EnableAllocStats is set.
+type AllocStats struct {
+	Slices int64 // make slice count
+	Maps   int64 // make map count
+	Chans  int64 // make chan count
+	Allocs int64 // heap alloc count (new and escaped variables)
+	Bytes  int64 // total bytes of slices, chan buffers and heap allocs, not include maps
+}
+
+// AllocStats returns the interpreted allocation stats.
+func (i *Interp) AllocStats() AllocStats {
+	return AllocStats{
+		Slices: atomic.LoadInt64(&i.allocs.Slices),
+		Maps:   atomic.LoadInt64(&i.allocs.Maps),
+		Chans:  atomic.LoadInt64(&i.allocs.Chans),
+		Allocs: atomic.LoadInt64(&i.allocs.Allocs),
+		Bytes:  atomic.LoadInt64(&i.allocs.Bytes),
+	}
+}
+
+// makeAllocStatsInstr wraps the allocation instr to record the alloc stats.
+func makeAllocStatsInstr(pfn *function, instr ssa.Instruction, fn func(fr *frame)) func(fr *frame) {
+	stats := &pfn.Interp.allocs
+	switch instr := instr.(type) {
+	case *ssa.Alloc:
+		if !instr.Heap {
+			return fn
+		}
+		size := int64(pfn.Interp.preToType(instr.Type()).Elem().Size())
+		if instr.Comment == "makeslice" {
+			// make([]T, n) with constant n is built as new([n]T)[:]
+			return func(fr *frame) {
+				fn(fr)
+				atomic.AddInt64(&stats.Slices, 1)
+				atomic.AddInt64(&stats.Bytes, size)
+			}
+		}
+		return func(fr *frame) {
+			fn(fr)
+			atomic.AddInt64(&stats.Allocs, 1)
+			atomic.AddInt64(&stats.Bytes, size)
+		}
+	case *ssa.MakeSlice:
+		ir := pfn.regIndex(instr)
+		size := int64(pfn.Interp.preToType(instr.Type()).Elem().Size())
+		return func(fr *frame) {
+			fn(fr)
+			atomic.AddInt64(&stats.Slices, 1)
+			atomic.AddInt64(&stats.Bytes, int64(reflect.ValueOf(fr.reg(ir)).Cap())*size)
+		}
+	case *ssa.MakeChan:
+		ir := pfn.regIndex(instr)
+		size := int64(pfn.Interp.preToType(instr.Type()).Elem().Size())
+		return func(fr *frame) {
+			fn(fr)
+			atomic.AddInt64(&stats.Chans, 1)
+			atomic.AddInt64(&stats.Bytes, int64(reflect.ValueOf(fr.reg(ir)).Cap())*size)
+		}
+	case *ssa.MakeMap:
+		return func(fr *frame) {
+			fn(fr)
+			atomic.AddInt64(&stats.Maps, 1)
+		}
+	}
+	return fn
+}
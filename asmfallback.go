@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"bytes"
+	"math"
+	"strings"
+)
+
+// The pure-Go fallback implementations of the assembly-backed functions, used
+// when the package is interpreted from source and the function has no body.
+func init() {
+	// math
+	RegisterExternal("math.archCeil", math.Ceil)
+	RegisterExternal("math.archFloor", math.Floor)
+	RegisterExternal("math.archTrunc", math.Trunc)
+	RegisterExternal("math.archSqrt", math.Sqrt)
+	RegisterExternal("math.archExp", math.Exp)
+	RegisterExternal("math.archExp2", math.Exp2)
+	RegisterExternal("math.archLog", math.Log)
+	RegisterExternal("math.archHypot", math.Hypot)
+	RegisterExternal("math.archMax", math.Max)
+	RegisterExternal("math.archMin", math.Min)
+	RegisterExternal("math.archModf", math.Modf)
+	// internal/bytealg
+	RegisterExternal("internal/bytealg.Compare", bytes.Compare)
+	RegisterExternal("internal/bytealg.Count", func(b []byte, c byte) int {
+		return bytes.Count(b, []byte{c})
+	})
+	RegisterExternal("internal/bytealg.CountString", func(s string, c byte) int {
+		return strings.Count(s, string([]byte{c}))
+	})
+	RegisterExternal("internal/bytealg.Index", bytes.Index)
+	RegisterExternal("internal/bytealg.IndexString", strings.Index)
+	RegisterExternal("internal/bytealg.IndexByte", bytes.IndexByte)
+	RegisterExternal("internal/bytealg.IndexByteString", strings.IndexByte)
+}
@@ -49,6 +49,9 @@ func (inter *Interp) callBuiltin(fr *frame, fn *ssa.Builtin, args []value, ssaAr
 		if i0+i1 < i0 {
 			panic(fr.runtimeError(fn, errAppendOutOfRange))
 		}
+		if inter.growSliceOverflow(v0, i0+i1) {
+			panic(fr.runtimeError(fn, errGrowSliceOutOfRange))
+		}
 		return reflect.AppendSlice(v0, v1).Interface()
 
 	case "copy": // copy([]T, []T) int or copy([]byte, string) int
@@ -59,7 +62,8 @@ func (inter *Interp) callBuiltin(fr *frame, fn *ssa.Builtin, args []value, ssaAr
 		return nil
 
 	case "delete": // delete(map[K]value, K)
-		reflect.ValueOf(args[0]).SetMapIndex(reflect.ValueOf(args[1]), reflect.Value{})
+		vm := reflect.ValueOf(args[0])
+		vm.SetMapIndex(mapValue(args[1], vm.Type().Key()), reflect.Value{})
 		return nil
 
 	case "print", "println": // print(any, ...)
@@ -247,7 +251,8 @@ func (inter *Interp) callBuiltinDiscardsResult(fr *frame, fn *ssa.Builtin, args
 		reflect.ValueOf(args[0]).Close()
 
 	case "delete": // delete(map[K]value, K)
-		reflect.ValueOf(args[0]).SetMapIndex(reflect.ValueOf(args[1]), reflect.Value{})
+		vm := reflect.ValueOf(args[0])
+		vm.SetMapIndex(mapValue(args[1], vm.Type().Key()), reflect.Value{})
 
 	case "print", "println": // print(any, ...)
 		ln := fn.Name() == "println"
@@ -334,6 +339,22 @@ func (inter *Interp) callBuiltinDiscardsResult(fr *frame, fn *ssa.Builtin, args
 	}
 }
 
+// growSliceOverflow reports whether growing v to length n exceeds the max memory size.
+func (interp *Interp) growSliceOverflow(v reflect.Value, n int) bool {
+	if n <= v.Cap() {
+		return false
+	}
+	size := v.Type().Elem().Size()
+	if size == 0 {
+		return false
+	}
+	maxLen := maxMemLen
+	if interp.ctx.intSize == 32 {
+		maxLen = maxMemLen32
+	}
+	return uintptr(n) > uintptr(maxLen)/size
+}
+
 // makeBuiltinByStack interprets a call to builtin fn with arguments args,
 // returning its result.
 func (interp *Interp) makeBuiltinByStack(fn *ssa.Builtin, ssaArgs []ssa.Value, ir register, ia []register) func(fr *frame) {
@@ -358,6 +379,9 @@ func (interp *Interp) makeBuiltinByStack(fn *ssa.Builtin, ssaArgs []ssa.Value, i
 			if i0+i1 < i0 {
 				panic(fr.runtimeError(fn, errAppendOutOfRange))
 			}
+			if interp.growSliceOverflow(v0, i0+i1) {
+				panic(fr.runtimeError(fn, errGrowSliceOutOfRange))
+			}
 			fr.setReg(ir, reflect.AppendSlice(v0, v1).Interface())
 		}
 	case "copy": // copy([]T, []T) int or copy([]byte, string) int
@@ -377,7 +401,8 @@ func (interp *Interp) makeBuiltinByStack(fn *ssa.Builtin, ssaArgs []ssa.Value, i
 		return func(fr *frame) {
 			arg0 := fr.reg(ia[0])
 			arg1 := fr.reg(ia[1])
-			reflect.ValueOf(arg0).SetMapIndex(reflect.ValueOf(arg1), reflect.Value{})
+			vm := reflect.ValueOf(arg0)
+			vm.SetMapIndex(mapValue(arg1, vm.Type().Key()), reflect.Value{})
 		}
 
 	case "print", "println": // print(any, ...)
@@ -592,11 +617,23 @@ func (interp *Interp) makeBuiltinByStack(fn *ssa.Builtin, ssaArgs []ssa.Value, i
 			fr.setReg(ir, (*byte)(unsafe.Pointer(data)))
 		}
 	case "Sizeof": // instance of generic function
+		if t := ssaArgs[0].Type(); !hasTypeParam(t) {
+			size := uintptr(interp.ctx.sizes.Sizeof(t))
+			return func(fr *frame) {
+				fr.setReg(ir, size)
+			}
+		}
 		return func(fr *frame) {
 			typ := reflect.TypeOf(fr.reg(ia[0]))
 			fr.setReg(ir, uintptr(typ.Size()))
 		}
 	case "Alignof": // instance of generic function
+		if t := ssaArgs[0].Type(); !hasTypeParam(t) {
+			align := uintptr(interp.ctx.sizes.Alignof(t))
+			return func(fr *frame) {
+				fr.setReg(ir, align)
+			}
+		}
 		return func(fr *frame) {
 			typ := reflect.TypeOf(fr.reg(ia[0]))
 			fr.setReg(ir, uintptr(typ.Align()))
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+// The files importing "C" are interpreted with the host package registered
+// as path "C" (e.g. by Context.RegisterRuntimePackage), it provides the Go
+// implementation of the cgo functions, types and constants used by them.
+// The cgo preamble is a comment and ignored.
+
+// checkCgo reports the first reference C.name of the files importing "C"
+// that is not resolved by the host package "C".
+func (sp *SourcePackage) checkCgo() error {
+	var scope *types.Scope
+	checked := false
+	for _, file := range sp.Files {
+		if !importsC(file) {
+			continue
+		}
+		if !checked {
+			checked = true
+			if pkg, err := sp.Context.Loader.Import("C"); err == nil {
+				scope = pkg.Scope()
+			}
+		}
+		var err error
+		ast.Inspect(file, func(n ast.Node) bool {
+			if err != nil {
+				return false
+			}
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == "C" && id.Obj == nil {
+				if scope == nil || scope.Lookup(sel.Sel.Name) == nil {
+					err = fmt.Errorf("%v: unresolved cgo reference C.%v, register it to the host package \"C\"",
+						sp.Context.FileSet.Position(sel.Pos()), sel.Sel.Name)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasCgoNotExportedError reports whether msg is the type checker error of
+// the reference C.name, the names of package "C" are not exported.
+func hasCgoNotExportedError(msg string) bool {
+	return strings.HasSuffix(msg, " not exported by package C")
+}
+
+func importsC(file *ast.File) bool {
+	for _, spec := range file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == "C" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"go/token"
+	"go/types"
+	"reflect"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// SetChannelHook set the callback called after each channel operation of the
+// interpreted program completed, op is "send" or "recv", the chosen case of
+// select is reported too. The hook does not change the blocking semantics, a
+// blocked operation is reported when it proceeds.
+func (ctx *Context) SetChannelHook(fn func(op string, ch interface{}, val interface{})) {
+	ctx.chanHook = fn
+}
+
+// makeChanHookInstr wraps the channel instr to call the context channel hook.
+func makeChanHookInstr(pfn *function, instr ssa.Instruction, fn func(fr *frame)) func(fr *frame) {
+	hook := pfn.Interp.ctx.chanHook
+	switch instr := instr.(type) {
+	case *ssa.Send:
+		ic := pfn.regIndex(instr.Chan)
+		ix := pfn.regIndex(instr.X)
+		zero := reflect.Zero(pfn.Interp.preToType(instr.X.Type())).Interface()
+		return func(fr *frame) {
+			fn(fr)
+			x := fr.reg(ix)
+			if x == nil {
+				x = zero
+			}
+			hook("send", fr.reg(ic), x)
+		}
+	case *ssa.UnOp:
+		if instr.Op != token.ARROW {
+			return fn
+		}
+		ir := pfn.regIndex(instr)
+		ix := pfn.regIndex(instr.X)
+		return func(fr *frame) {
+			fn(fr)
+			v := fr.reg(ir)
+			if instr.CommaOk {
+				v = v.(tuple)[0]
+			}
+			hook("recv", fr.reg(ix), v)
+		}
+	case *ssa.Select:
+		ir := pfn.regIndex(instr)
+		ic := make([]register, len(instr.States))
+		is := make([]register, len(instr.States))
+		for i, state := range instr.States {
+			ic[i] = pfn.regIndex(state.Chan)
+			if state.Send != nil {
+				is[i] = pfn.regIndex(state.Send)
+			}
+		}
+		return func(fr *frame) {
+			fn(fr)
+			r := fr.reg(ir).(tuple)
+			chosen := r[0].(int)
+			if chosen < 0 {
+				return
+			}
+			state := instr.States[chosen]
+			if state.Dir == types.SendOnly {
+				x := fr.reg(is[chosen])
+				if x == nil {
+					x = reflect.Zero(pfn.Interp.preToType(state.Send.Type())).Interface()
+				}
+				hook("send", fr.reg(ic[chosen]), x)
+				return
+			}
+			// received values follow chosen and recvOk in the order of recv states
+			n := 2
+			for _, st := range instr.States[:chosen] {
+				if st.Dir == types.RecvOnly {
+					n++
+				}
+			}
+			hook("recv", fr.reg(ic[chosen]), r[n])
+		}
+	}
+	return fn
+}
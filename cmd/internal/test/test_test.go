@@ -0,0 +1,50 @@
+/*
+ Copyright 2021 The GoPlus Authors (goplus.org)
+
+ Licensed under the Apache License, Version 2.0 (the "License");
+ you may not use this file except in compliance with the License.
+ You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+ Unless required by applicable law or agreed to in writing, software
+ distributed under the License is distributed on an "AS IS" BASIS,
+ WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ See the License for the specific language governing permissions and
+ limitations under the License.
+*/
+
+package test
+
+import (
+	"testing"
+
+	"github.com/goplus/igop"
+	_ "github.com/goplus/igop/pkg"
+)
+
+func TestRunTestFlags(t *testing.T) {
+	ctx := igop.NewContext(0)
+	err := ctx.RunTest("../../../testdata/testrun", []string{"-test.run", "^TestAdd$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = igop.NewContext(0)
+	err = ctx.RunTest("../../../testdata/testrun", nil)
+	if err != igop.ErrTestFailed {
+		t.Fatalf("must failed: %v", err)
+	}
+}
+
+func TestRunTestSubtests(t *testing.T) {
+	ctx := igop.NewContext(0)
+	err := ctx.RunTest("../../../testdata/testsub", []string{"-test.run", "^TestSubtests$"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = igop.NewContext(0)
+	err = ctx.RunTest("../../../testdata/testsub", []string{"-test.run", "^TestSubFatal$"})
+	if err != igop.ErrTestFailed {
+		t.Fatalf("must failed: %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// The os.Args, flag.CommandLine and flag.Usage of the interpreted program are
+// the host variables by default. With SupportMultipleInterp every interp has
+// its own, then the programs run concurrently in one process do not interfere.
+
+func init() {
+	RegisterExternal("flag.Parse", func(fr *frame) {
+		i := fr.interp
+		if !i.isolated() {
+			flag.Parse()
+			return
+		}
+		// same as flag.ExitOnError, but exit the interpreted program only
+		switch err := i.flags.Parse(i.args[1:]); err {
+		case nil:
+		case flag.ErrHelp:
+			i.Exit(0)
+		default:
+			i.Exit(2)
+		}
+	})
+	RegisterExternal("flag.Parsed", func(fr *frame) bool {
+		return fr.interp.commandLine().Parsed()
+	})
+	RegisterExternal("flag.Arg", func(fr *frame, i int) string {
+		return fr.interp.commandLine().Arg(i)
+	})
+	RegisterExternal("flag.Args", func(fr *frame) []string {
+		return fr.interp.commandLine().Args()
+	})
+	RegisterExternal("flag.NArg", func(fr *frame) int {
+		return fr.interp.commandLine().NArg()
+	})
+	RegisterExternal("flag.NFlag", func(fr *frame) int {
+		return fr.interp.commandLine().NFlag()
+	})
+	RegisterExternal("flag.Lookup", func(fr *frame, name string) *flag.Flag {
+		return fr.interp.commandLine().Lookup(name)
+	})
+	RegisterExternal("flag.Set", func(fr *frame, name, value string) error {
+		return fr.interp.commandLine().Set(name, value)
+	})
+	RegisterExternal("flag.Visit", func(fr *frame, fn func(*flag.Flag)) {
+		fr.interp.commandLine().Visit(fn)
+	})
+	RegisterExternal("flag.VisitAll", func(fr *frame, fn func(*flag.Flag)) {
+		fr.interp.commandLine().VisitAll(fn)
+	})
+	RegisterExternal("flag.PrintDefaults", func(fr *frame) {
+		fr.interp.commandLine().PrintDefaults()
+	})
+	RegisterExternal("flag.Var", func(fr *frame, value flag.Value, name string, usage string) {
+		fr.interp.commandLine().Var(value, name, usage)
+	})
+	RegisterExternal("flag.Func", func(fr *frame, name, usage string, fn func(string) error) {
+		fr.interp.commandLine().Func(name, usage, fn)
+	})
+	RegisterExternal("flag.Bool", func(fr *frame, name string, value bool, usage string) *bool {
+		return fr.interp.commandLine().Bool(name, value, usage)
+	})
+	RegisterExternal("flag.BoolVar", func(fr *frame, p *bool, name string, value bool, usage string) {
+		fr.interp.commandLine().BoolVar(p, name, value, usage)
+	})
+	RegisterExternal("flag.Duration", func(fr *frame, name string, value time.Duration, usage string) *time.Duration {
+		return fr.interp.commandLine().Duration(name, value, usage)
+	})
+	RegisterExternal("flag.DurationVar", func(fr *frame, p *time.Duration, name string, value time.Duration, usage string) {
+		fr.interp.commandLine().DurationVar(p, name, value, usage)
+	})
+	RegisterExternal("flag.Float64", func(fr *frame, name string, value float64, usage string) *float64 {
+		return fr.interp.commandLine().Float64(name, value, usage)
+	})
+	RegisterExternal("flag.Float64Var", func(fr *frame, p *float64, name string, value float64, usage string) {
+		fr.interp.commandLine().Float64Var(p, name, value, usage)
+	})
+	RegisterExternal("flag.Int", func(fr *frame, name string, value int, usage string) *int {
+		return fr.interp.commandLine().Int(name, value, usage)
+	})
+	RegisterExternal("flag.IntVar", func(fr *frame, p *int, name string, value int, usage string) {
+		fr.interp.commandLine().IntVar(p, name, value, usage)
+	})
+	RegisterExternal("flag.Int64", func(fr *frame, name string, value int64, usage string) *int64 {
+		return fr.interp.commandLine().Int64(name, value, usage)
+	})
+	RegisterExternal("flag.Int64Var", func(fr *frame, p *int64, name string, value int64, usage string) {
+		fr.interp.commandLine().Int64Var(p, name, value, usage)
+	})
+	RegisterExternal("flag.String", func(fr *frame, name string, value string, usage string) *string {
+		return fr.interp.commandLine().String(name, value, usage)
+	})
+	RegisterExternal("flag.StringVar", func(fr *frame, p *string, name string, value string, usage string) {
+		fr.interp.commandLine().StringVar(p, name, value, usage)
+	})
+	RegisterExternal("flag.Uint", func(fr *frame, name string, value uint, usage string) *uint {
+		return fr.interp.commandLine().Uint(name, value, usage)
+	})
+	RegisterExternal("flag.UintVar", func(fr *frame, p *uint, name string, value uint, usage string) {
+		fr.interp.commandLine().UintVar(p, name, value, usage)
+	})
+	RegisterExternal("flag.Uint64", func(fr *frame, name string, value uint64, usage string) *uint64 {
+		return fr.interp.commandLine().Uint64(name, value, usage)
+	})
+	RegisterExternal("flag.Uint64Var", func(fr *frame, p *uint64, name string, value uint64, usage string) {
+		fr.interp.commandLine().Uint64Var(p, name, value, usage)
+	})
+}
+
+// isolated reports whether the interp has its own os.Args and flag.CommandLine.
+func (i *Interp) isolated() bool {
+	return i.ctx.Mode&SupportMultipleInterp != 0
+}
+
+func (i *Interp) commandLine() *flag.FlagSet {
+	if i.isolated() {
+		return i.flags
+	}
+	return flag.CommandLine
+}
+
+// isolatedVar returns the address of the interp own host variable key.
+func (i *Interp) isolatedVar(key string) (interface{}, bool) {
+	switch key {
+	case "os.Args":
+		return &i.args, true
+	case "flag.CommandLine":
+		return &i.flags, true
+	case "flag.Usage":
+		return &i.usage, true
+	}
+	return nil, false
+}
+
+// setArgs set os.Args of the program and reset flag.CommandLine.
+func (i *Interp) setArgs(args []string) {
+	if !i.isolated() {
+		os.Args = args
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		return
+	}
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	if i.ctx.stderr != nil {
+		fs.SetOutput(i.ctx.stderr)
+	}
+	fs.Usage = func() {
+		i.usage()
+	}
+	i.args = args
+	i.flags = fs
+	i.usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		fs.PrintDefaults()
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"go/types"
 	"io"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -36,6 +37,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"testing"
 	"time"
 
 	"github.com/goplus/igop/load"
@@ -54,8 +56,12 @@ const (
 	EnablePrintAny                         // Enable builtin print for any type ( struct/array )
 	EnableNoStrict                         // Enable no strict mode
 	ExperimentalSupportGC                  // experimental support runtime.GC
-	SupportMultipleInterp                  // Support multiple interp, must manual release interp reflectx icall.
+	SupportMultipleInterp                  // Support multiple interp, must manual release interp reflectx icall. os.Args and flag.CommandLine are per interp.
 	CheckGopOverloadFunc                   // Check and skip gop overload func
+	EnableAllocStats                       // Enable interpreted allocation accounting, see Interp.AllocStats
+	EnableHostPanicStack                   // Capture the host stack of interpreter internal panics in FatalError
+	EnableDiagnostics                      // Collect type checker warnings and vet diagnostics, see Context.Diagnostics
+	EnableVirtualSignal                    // Deliver os/signal notifications by Interp.SendSignal only, not the host signals
 )
 
 // Loader types loader interface
@@ -70,25 +76,46 @@ type Loader interface {
 
 // Context ssa context
 type Context struct {
-	Loader       Loader                                                   // types loader
-	BuildContext build.Context                                            // build context, default build.Default
-	RunContext   context.Context                                          // run context, default unset
-	output       io.Writer                                                // capture print/println output
-	FileSet      *token.FileSet                                           // file set
-	sizes        types.Sizes                                              // types unsafe sizes
-	Lookup       func(root, path string) (dir string, found bool)         // lookup external import
-	evalCallFn   func(interp *Interp, call *ssa.Call, res ...interface{}) // internal eval func for repl
-	debugFunc    func(*DebugInfo)                                         // debug func
-	panicFunc    func(*PanicInfo)                                         // panic func
-	pkgs         map[string]*SourcePackage                                // imports
-	override     map[string]reflect.Value                                 // override function
-	evalInit     map[string]bool                                          // eval init check
-	nestedMap    map[*types.Named]int                                     // nested named index
-	root         string                                                   // project root
-	callForPool  int                                                      // least call count for enable function pool
-	Mode         Mode                                                     // mode
-	BuilderMode  ssa.BuilderMode                                          // ssa builder mode
-	evalMode     bool                                                     // eval mode
+	Loader       Loader                                                            // types loader
+	BuildContext build.Context                                                     // build context, default build.Default
+	RunContext   context.Context                                                   // run context, default unset
+	output       io.Writer                                                         // capture print/println output
+	stdout       *outputFile                                                       // interpreted os.Stdout
+	stderr       *outputFile                                                       // interpreted os.Stderr
+	FileSet      *token.FileSet                                                    // file set
+	sizes        types.Sizes                                                       // types unsafe sizes
+	Lookup       func(root, path string) (dir string, found bool)                  // lookup external import
+	evalCallFn   func(interp *Interp, call *ssa.Call, res ...interface{})          // internal eval func for repl
+	debugFunc    func(*DebugInfo)                                                  // debug func
+	panicFunc    func(*PanicInfo)                                                  // panic func
+	panicFilter  func(v interface{}) (interface{}, bool)                           // filter of unrecovered panics
+	pkgs         map[string]*SourcePackage                                         // imports
+	override     map[string]reflect.Value                                          // override function
+	faults       map[string]*faultInjector                                         // fault injection function
+	intrinsics   map[string]Intrinsic                                              // native implementation of interpreted function
+	unresolved   func(fullname string, sig *types.Signature) (reflect.Value, bool) // unresolved func handler
+	coverFunc    func(fn *Function, pc int)                                        // executed instr callback
+	fsRestore    func()                                                            // restore the overrides replaced by SetFileSystem
+	dumpFilter   func(fn *ssa.Function) bool                                       // filter of functions printed by ssa.PrintFunctions
+	chanHook     func(op string, ch interface{}, val interface{})                  // channel operation callback
+	diagnostics  []Diagnostic                                                      // collected diagnostics
+	evalInit     map[string]bool                                                   // eval init check
+	nestedMap    map[*types.Named]int                                              // nested named index
+	root         string                                                            // project root
+	callForPool  int                                                               // least call count for enable function pool
+	intSize      int                                                               // simulate int/uint bit size, 0 is host size
+	rand         *rand.Rand                                                        // math/rand global source set by SetRandSeed
+	randSeed     int64                                                             // seed set by SetRandSeed
+	randRestore  func()                                                            // restore the overrides replaced by SetRandSeed
+	resolver     func(importPath string) (dir string, found bool)                  // import resolver
+	modLookup    func(importPath string) (dir string, found bool)                  // lookup by the go.mod set by SetModFile
+	linkValues   map[string]string                                                 // -X link values, pkgpath.name -> value
+	goVersion    string                                                            // language version, default the module go directive
+	timeout      time.Duration                                                     // wall-clock limit of run
+	whitelist    map[string]bool                                                   // importable packages, nil allows all
+	Mode         Mode                                                              // mode
+	BuilderMode  ssa.BuilderMode                                                   // ssa builder mode
+	evalMode     bool                                                              // eval mode
 }
 
 func (ctx *Context) setRoot(root string) {
@@ -96,6 +123,16 @@ func (ctx *Context) setRoot(root string) {
 }
 
 func (ctx *Context) lookupPath(path string) (dir string, found bool) {
+	if ctx.resolver != nil {
+		if dir, found = ctx.resolver(path); found {
+			return
+		}
+	}
+	if ctx.modLookup != nil {
+		if dir, found = ctx.modLookup(path); found {
+			return
+		}
+	}
 	if ctx.Lookup != nil {
 		dir, found = ctx.Lookup(ctx.root, path)
 	}
@@ -121,21 +158,37 @@ type SourcePackage struct {
 
 func (sp *SourcePackage) Load() (err error) {
 	if sp.Info == nil {
+		if err = sp.checkCgo(); err != nil {
+			return
+		}
 		sp.Info = newTypesInfo()
 		if sp.Importer == nil {
-			sp.Importer = NewImporter(sp.Context)
+			imp := NewImporter(sp.Context)
+			imp.unrestricted = sp.Register
+			sp.Importer = imp
 		}
 		conf := &types.Config{
 			Sizes:    sp.Context.sizes,
 			Importer: sp.Importer,
 		}
+		version := sp.Context.goVersion
+		if version == "" && sp.Dir != "" {
+			version = load.GoVersion(sp.Dir)
+		}
+		setGoVersion(conf, sp.Info, version)
 		if sp.Context.evalMode {
 			conf.DisableUnusedImportCheck = true
 		}
 		if sp.Context.Mode&EnableNoStrict != 0 {
 			conf.Error = func(e error) {
 				if te, ok := e.(types.Error); ok {
+					if hasCgoNotExportedError(te.Msg) {
+						return
+					}
 					if hasTypesNotUsedError(te.Msg) {
+						if sp.Context.Mode&EnableDiagnostics != 0 {
+							sp.Context.addDiagnostic(te.Pos, "types", te.Msg)
+						}
 						println(fmt.Sprintf("igop warning: %v", e))
 						return
 					}
@@ -146,15 +199,36 @@ func (sp *SourcePackage) Load() (err error) {
 			}
 		} else {
 			conf.Error = func(e error) {
+				if te, ok := e.(types.Error); ok && hasCgoNotExportedError(te.Msg) {
+					return
+				}
 				if err == nil {
 					err = e
 				}
 			}
 		}
 		types.NewChecker(conf, sp.Context.FileSet, sp.Package, sp.Info).Files(sp.Files)
+		if err == nil {
+			var changed bool
+			changed, err = rewriteRangeFunc(sp.Context.FileSet, sp.Package, sp.Info, sp.Files)
+			if changed {
+				// check again the rewritten files
+				pkg := types.NewPackage(sp.Package.Path(), sp.Package.Name())
+				if sp.Context.pkgs[pkg.Path()] == sp {
+					sp.Context.Loader.SetImport(pkg.Path(), pkg, nil)
+				}
+				sp.Package = pkg
+				sp.Info = newTypesInfo()
+				setGoVersion(conf, sp.Info, version)
+				types.NewChecker(conf, sp.Context.FileSet, sp.Package, sp.Info).Files(sp.Files)
+			}
+		}
 		if err == nil {
 			sp.Links, err = load.ParseLinkname(sp.Context.FileSet, sp.Package.Path(), sp.Files)
 		}
+		if err == nil && sp.Context.Mode&EnableDiagnostics != 0 {
+			err = sp.vet()
+		}
 	}
 	return
 }
@@ -198,9 +272,140 @@ func (ctx *Context) SetEvalMode(b bool) {
 	ctx.evalMode = b
 }
 
+// SetBuilderMode set the ssa builder mode flags, e.g. ssa.SanityCheckFunctions
+// or ssa.NaiveForm. The ssa.PrintFunctions and ssa.GlobalDebug flags enabled
+// by EnableDumpInstr and SetDebug are kept.
+func (ctx *Context) SetBuilderMode(mode ssa.BuilderMode) {
+	ctx.BuilderMode = mode | ctx.BuilderMode&(ssa.PrintFunctions|ssa.GlobalDebug)
+}
+
+// SetTimeout set the wall-clock limit of running the program, the run is
+// interrupted after d elapsed and returns ErrTimeout. A zero d disables it.
+func (ctx *Context) SetTimeout(d time.Duration) {
+	ctx.timeout = d
+}
+
+// SetPackageWhitelist restrict the packages importable by interpreted programs
+// to pkgs, other imports fail at load time with ErrPackageNotAllowed.
+// A nil pkgs allows all packages.
+func (ctx *Context) SetPackageWhitelist(pkgs []string) {
+	if pkgs == nil {
+		ctx.whitelist = nil
+		return
+	}
+	ctx.whitelist = make(map[string]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		ctx.whitelist[pkg] = true
+	}
+}
+
+// SetSizes set the sizes of the target platform, it is used by the type
+// checker and the interpreted unsafe.Sizeof, Alignof and Offsetof.
+// The values are stored in the host layout, so the program that converts
+// the unsafe offsets to pointers must use sizes that match the host.
+func (ctx *Context) SetSizes(sizes types.Sizes) {
+	ctx.sizes = sizes
+}
+
 // SetUnsafeSizes set the sizing functions for package unsafe.
+//
+// Deprecated: use SetSizes.
 func (ctx *Context) SetUnsafeSizes(sizes types.Sizes) {
-	ctx.sizes = sizes
+	ctx.SetSizes(sizes)
+}
+
+// RegisterRuntimePackage register the external package to the context loader
+// after creation, the package must be registered before it is imported.
+func (ctx *Context) RegisterRuntimePackage(pkg *Package) error {
+	r, ok := ctx.Loader.(interface {
+		RegisterPackage(pkg *Package) error
+	})
+	if !ok {
+		return fmt.Errorf("register package %v: loader not support runtime package", pkg.Path)
+	}
+	return r.RegisterPackage(pkg)
+}
+
+// SetImportResolver set the resolver to lookup the source directory of the
+// import path, it takes precedence over the default Lookup and build.Import.
+func (ctx *Context) SetImportResolver(resolver func(importPath string) (dir string, found bool)) {
+	ctx.resolver = resolver
+}
+
+// SetModFile set the go.mod file used to resolve the imports instead of
+// the module of the source root. The packages of the module, the local replace
+// directives and the required modules in the module cache are resolved.
+func (ctx *Context) SetModFile(file string) error {
+	lookup, err := load.ModLookup(file)
+	if err != nil {
+		return err
+	}
+	ctx.modLookup = lookup
+	return nil
+}
+
+// SetUnresolvedFuncHandler set the handler consulted when an external function
+// has no registered implementation. The handler returns the implementation
+// of the function fullname (e.g. "mypkg.Helper") and true if found, the found
+// implementation is cached in the context and the handler is not consulted again.
+func (ctx *Context) SetUnresolvedFuncHandler(handler func(fullname string, sig *types.Signature) (reflect.Value, bool)) {
+	ctx.unresolved = handler
+}
+
+// SetLinkValue set the string variable pkgpath.name to value before the package
+// initialization, mirroring the linker flag -X pkgpath.name=value. The value
+// replaces the constant initial value, a non-constant initializer overwrites it.
+func (ctx *Context) SetLinkValue(pkgpath, name, value string) {
+	if ctx.linkValues == nil {
+		ctx.linkValues = make(map[string]string)
+	}
+	ctx.linkValues[pkgpath+"."+name] = value
+}
+
+// SetGoVersion set the language version used to check and build packages,
+// e.g. "go1.21". The default is the go directive of the package module,
+// or the latest version if the module is not found.
+func (ctx *Context) SetGoVersion(version string) {
+	if version != "" && !strings.HasPrefix(version, "go") {
+		version = "go" + version
+	}
+	ctx.goVersion = version
+}
+
+// SetGOOS set the target operating system used to match build constraints.
+func (ctx *Context) SetGOOS(goos string) {
+	ctx.BuildContext.GOOS = goos
+}
+
+// SetGOARCH set the target architecture used to match build constraints.
+// The unsafe sizes are not changed, see SetSizes.
+func (ctx *Context) SetGOARCH(goarch string) {
+	ctx.BuildContext.GOARCH = goarch
+}
+
+// SetBuildTags set additional build tags used to match build constraints.
+func (ctx *Context) SetBuildTags(tags ...string) {
+	ctx.BuildContext.BuildTags = tags
+}
+
+// SetIntSize set the bit size of int and uint arithmetic in the target program.
+// size 32 simulate a 32-bit target on a 64-bit host, size 0 reset to host size.
+// uintptr and the unsafe sizes are not changed, use SetSizes for them.
+func (ctx *Context) SetIntSize(size int) error {
+	switch size {
+	case 0:
+		ctx.intSize = 0
+	case 32:
+		ctx.intSize = 32
+	case 64:
+		if intSize != 64 {
+			return fmt.Errorf("unsupported int size %v on %v", size, runtime.GOARCH)
+		}
+		ctx.intSize = 0
+	default:
+		return fmt.Errorf("unsupported int size %v", size)
+	}
+	return nil
 }
 
 // SetLeastCallForEnablePool set least call count for enable function pool, default 64
@@ -217,8 +422,31 @@ func (ctx *Context) SetPanic(fn func(*PanicInfo)) {
 	ctx.panicFunc = fn
 }
 
+// SetPanicFilter set the filter called with the value of the panic not recovered
+// by the interpreted program. The filter returns the value reported in the
+// error instead, or handled true to suppress the panic and end the run normally.
+func (ctx *Context) SetPanicFilter(fn func(v interface{}) (interface{}, bool)) {
+	ctx.panicFilter = fn
+}
+
+// SetCoverFunc set the callback called before each instruction executed,
+// pc is the instruction index of fn, use fn.PositionForPC to get its position.
+func (ctx *Context) SetCoverFunc(fn func(fn *Function, pc int)) {
+	ctx.coverFunc = fn
+}
+
+// SetDumpFilter set the filter of the functions printed by the ssa.PrintFunctions
+// builder mode, set by EnableDumpInstr or SetBuilderMode. Only the SSA code of
+// the package functions, methods and their anonymous functions that fn returns
+// true are printed.
+func (ctx *Context) SetDumpFilter(fn func(fn *ssa.Function) bool) {
+	ctx.dumpFilter = fn
+}
+
 type Frame = frame
 
+type Function = function
+
 func (fr *Frame) CallerFrames() (frames []runtime.Frame) {
 	rpc := make([]uintptr, 64)
 	n := runtimeCallers(fr, 1, rpc)
@@ -274,6 +502,27 @@ func (ctx *Context) RegisterExternal(key string, i interface{}) {
 	}
 }
 
+// replaceOverride set the override funcs and returns the function that
+// restores the overrides replaced.
+func (ctx *Context) replaceOverride(funcs map[string]reflect.Value) (restore func()) {
+	saved := make(map[string]reflect.Value)
+	for name, fn := range funcs {
+		if v, ok := ctx.override[name]; ok {
+			saved[name] = v
+		}
+		ctx.override[name] = fn
+	}
+	return func() {
+		for name := range funcs {
+			if v, ok := saved[name]; ok {
+				ctx.override[name] = v
+			} else {
+				delete(ctx.override, name)
+			}
+		}
+	}
+}
+
 // SetPrintOutput is captured builtin print/println output
 func (ctx *Context) SetPrintOutput(output *bytes.Buffer) {
 	ctx.output = output
@@ -283,6 +532,9 @@ func (ctx *Context) writeOutput(data []byte) (n int, err error) {
 	if ctx.output != nil {
 		return ctx.output.Write(data)
 	}
+	if ctx.stdout != nil {
+		return ctx.stdout.Write(data)
+	}
 	return os.Stdout.Write(data)
 }
 
@@ -601,56 +853,71 @@ func (ctx *Context) RunPkg(mainPkg *ssa.Package, input string, args []string) (e
 }
 
 func (ctx *Context) RunInterp(interp *Interp, input string, args []string) (exitCode int, err error) {
+	return ctx.runEntry(interp, "main", input, args)
+}
+
+// RunEntry run the package with the entry function instead of main, the entry
+// function must have no parameters and results, e.g. func Start().
+func (ctx *Context) RunEntry(pkg *ssa.Package, entry string, args []string) (exitCode int, err error) {
+	interp, err := ctx.NewInterp(pkg)
+	if err != nil {
+		return 2, err
+	}
+	return ctx.runEntry(interp, entry, pkg.Pkg.Path(), args)
+}
+
+func (ctx *Context) runEntry(interp *Interp, entry string, input string, args []string) (exitCode int, err error) {
+	if ctx.timeout > 0 {
+		parent := ctx.RunContext
+		if parent == nil {
+			parent = context.Background()
+		}
+		rctx, cancel := context.WithTimeout(parent, ctx.timeout)
+		defer cancel()
+		exitCode, err = ctx.runInterpWithContext(interp, entry, input, args, rctx)
+		if rctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+			return 2, ErrTimeout
+		}
+		return
+	}
 	if ctx.RunContext != nil {
-		return ctx.runInterpWithContext(interp, input, args, ctx.RunContext)
+		return ctx.runInterpWithContext(interp, entry, input, args, ctx.RunContext)
 	}
-	return ctx.runInterp(interp, input, args)
+	return ctx.runInterp(interp, entry, input, args)
 }
 
-func (p *Context) runInterpWithContext(interp *Interp, input string, args []string, ctx context.Context) (int, error) {
-	var exitCode int
-	var err error
-	ch := make(chan error, 1)
-	interp.cherror = make(chan PanicError)
+func (p *Context) runInterpWithContext(interp *Interp, entry string, input string, args []string, ctx context.Context) (int, error) {
+	type result struct {
+		exitCode int
+		err      error
+	}
+	ch := make(chan result, 1)
 	go func() {
-		exitCode, err = p.runInterp(interp, input, args)
-		ch <- err
+		exitCode, err := p.runInterp(interp, entry, input, args)
+		ch <- result{exitCode, err}
 	}()
 	select {
 	case <-ctx.Done():
 		interp.Abort()
 		select {
 		case <-time.After(1e9):
-			exitCode = 2
-			err = fmt.Errorf("interrupt timeout: all goroutines are asleep - deadlock!")
+			return 2, fmt.Errorf("interrupt timeout: all goroutines are asleep - deadlock!")
 		case <-ch:
-			err = ctx.Err()
-		}
-	case err = <-ch:
-	case e := <-interp.cherror:
-		switch v := e.Value.(type) {
-		case exitPanic:
-			exitCode = int(v)
-		default:
-			err = e
-			interp.Abort()
-			exitCode = 2
+			return 2, ctx.Err()
 		}
+	case r := <-ch:
+		return r.exitCode, r.err
 	}
-	return exitCode, err
 }
 
-func (ctx *Context) runInterp(interp *Interp, input string, args []string) (exitCode int, err error) {
+func (ctx *Context) runInterp(interp *Interp, entry string, input string, args []string) (exitCode int, err error) {
+	defer ctx.closeOutput()
 	// reset os args and flag
-	os.Args = []string{input}
-	if args != nil {
-		os.Args = append(os.Args, args...)
-	}
-	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	interp.setArgs(append([]string{input}, args...))
 	if err = interp.RunInit(); err != nil {
 		return 2, err
 	}
-	return interp.RunMain()
+	return interp.RunEntry(entry)
 }
 
 func (ctx *Context) RunFunc(mainPkg *ssa.Package, fnname string, args ...Value) (ret Value, err error) {
@@ -680,17 +947,27 @@ func (ctx *Context) TestPkg(pkg *ssa.Package, input string, args []string) error
 	if args != nil {
 		os.Args = append(os.Args, args...)
 	}
-	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	if _, ok := ctx.Loader.Installed("testing"); ok {
+		defer resetTestFlags(os.Args[0])()
+	} else {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}
 	interp, err := NewInterp(ctx, pkg)
 	if err != nil {
 		failed = true
 		fmt.Printf("create interp failed: %v\n", err)
 	}
+	if interp.isolated() {
+		// the testing flags are registered to the host flag.CommandLine
+		interp.args = os.Args
+		interp.flags = flag.CommandLine
+	}
 	if err = interp.RunInit(); err != nil {
 		failed = true
 		fmt.Printf("init error: %v\n", err)
 	}
 	exitCode, _ := interp.RunMain()
+	ctx.closeOutput()
 	if exitCode != 0 {
 		failed = true
 	}
@@ -700,6 +977,30 @@ func (ctx *Context) TestPkg(pkg *ssa.Package, input string, args []string) error
 	return nil
 }
 
+// resetTestFlags reset flag.CommandLine and keep the installed testing flags.
+// The testing package registers its flags only once, the -test.run, -test.bench
+// and -test.v flags must be passed through to the new flag set.
+// It returns a func to restore the values of the testing flags, they are shared
+// with the host testing when run in a test binary.
+func resetTestFlags(name string) (restore func()) {
+	testing.Init()
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	saved := make(map[*flag.Flag]string)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "test.") {
+			saved[f] = f.Value.String()
+			f.Value.Set(f.DefValue)
+			fs.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+	flag.CommandLine = fs
+	return func() {
+		for f, v := range saved {
+			f.Value.Set(v)
+		}
+	}
+}
+
 func (ctx *Context) RunFile(filename string, src interface{}, args []string) (exitCode int, err error) {
 	pkg, err := ctx.LoadFile(filename, src)
 	if err != nil {
@@ -708,6 +1009,33 @@ func (ctx *Context) RunFile(filename string, src interface{}, args []string) (ex
 	return ctx.RunPkg(pkg, filename, args)
 }
 
+// RunGopFile run the Go+ source file. The file is processed to Go source by the
+// file process registered for its extension, or .gop if the extension is not
+// registered. Import github.com/goplus/igop/gopbuild to register Go+ support.
+func (ctx *Context) RunGopFile(filename string, src interface{}, args []string) (exitCode int, err error) {
+	fn, ok := sourceProcessor[filepath.Ext(filename)]
+	if !ok {
+		if fn, ok = sourceProcessor[".gop"]; !ok {
+			return 2, fmt.Errorf("run %v: no Go+ file process registered, import github.com/goplus/igop/gopbuild", filename)
+		}
+	}
+	data, err := fn(ctx, filename, src)
+	if err != nil {
+		return 2, err
+	}
+	file, err := parser.ParseFile(ctx.FileSet, filename, data, parser.ParseComments)
+	if err != nil {
+		return 2, err
+	}
+	root, _ := filepath.Split(filename)
+	ctx.setRoot(root)
+	pkg, err := ctx.LoadAstFile(file.Name.Name, file)
+	if err != nil {
+		return 2, err
+	}
+	return ctx.RunPkg(pkg, filename, args)
+}
+
 func (ctx *Context) Run(path string, args []string) (exitCode int, err error) {
 	if strings.HasSuffix(path, ".go") {
 		return ctx.RunFile(path, nil, args)
@@ -750,6 +1078,10 @@ func (ctx *Context) buildPackage(sp *SourcePackage) (pkg *ssa.Package, err error
 		}()
 	}
 	mode := ctx.BuilderMode
+	if ctx.dumpFilter != nil {
+		// print the matched functions after build
+		mode &^= ssa.PrintFunctions
+	}
 	if enabledTypeParam {
 		mode |= ssa.InstantiateGenerics
 	}
@@ -821,9 +1153,48 @@ func (ctx *Context) buildPackage(sp *SourcePackage) (pkg *ssa.Package, err error
 	pkg = prog.CreatePackage(sp.Package, sp.Files, sp.Info, false)
 	pkg.Build()
 	ctx.checkNested(sp.Package, sp.Info)
+	if ctx.dumpFilter != nil && ctx.BuilderMode&ssa.PrintFunctions != 0 {
+		ctx.dumpFuncs(prog)
+	}
 	return
 }
 
+func (ctx *Context) dumpFuncs(prog *ssa.Program) {
+	var fns []*ssa.Function
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		if fn == nil {
+			return
+		}
+		if ctx.dumpFilter(fn) {
+			fns = append(fns, fn)
+		}
+		for _, anon := range fn.AnonFuncs {
+			visit(anon)
+		}
+	}
+	for _, pkg := range prog.AllPackages() {
+		for _, m := range pkg.Members {
+			switch m := m.(type) {
+			case *ssa.Function:
+				visit(m)
+			case *ssa.Type:
+				if named, ok := m.Type().(*types.Named); ok {
+					for i := 0; i < named.NumMethods(); i++ {
+						visit(prog.FuncValue(named.Method(i)))
+					}
+				}
+			}
+		}
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		return fns[i].String() < fns[j].String()
+	})
+	for _, fn := range fns {
+		fn.WriteTo(os.Stdout)
+	}
+}
+
 func (ctx *Context) checkNested(pkg *types.Package, info *types.Info) {
 	var nestedList []*types.Named
 	for k, v := range info.Scopes {
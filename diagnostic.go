@@ -0,0 +1,174 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"fmt"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/assign"
+	"golang.org/x/tools/go/analysis/passes/bools"
+	"golang.org/x/tools/go/analysis/passes/nilfunc"
+	"golang.org/x/tools/go/analysis/passes/printf"
+	"golang.org/x/tools/go/analysis/passes/shift"
+	"golang.org/x/tools/go/analysis/passes/unreachable"
+)
+
+// Diagnostic is a warning of the source package reported when
+// EnableDiagnostics is set, it does not fail the build.
+type Diagnostic struct {
+	Pos      token.Position // position of the source
+	Category string         // "types" for soft type errors, or the vet analyzer name
+	Message  string         // warning message
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %v", d.Pos, d.Category, d.Message)
+}
+
+// Diagnostics returns the diagnostics of the loaded source packages,
+// EnableDiagnostics must be set.
+func (ctx *Context) Diagnostics() []Diagnostic {
+	return ctx.diagnostics
+}
+
+// vetAnalyzers is the vet checks run on the source packages.
+var vetAnalyzers = []*analysis.Analyzer{
+	assign.Analyzer,
+	bools.Analyzer,
+	nilfunc.Analyzer,
+	printf.Analyzer,
+	shift.Analyzer,
+	unreachable.Analyzer,
+}
+
+func (ctx *Context) addDiagnostic(pos token.Pos, category string, msg string) {
+	ctx.diagnostics = append(ctx.diagnostics, Diagnostic{
+		Pos:      ctx.FileSet.Position(pos),
+		Category: category,
+		Message:  msg,
+	})
+}
+
+// vet run the vet analyzers on the checked source package and
+// record the reports as diagnostics.
+func (sp *SourcePackage) vet() error {
+	var diags []Diagnostic
+	facts := make(map[objectFactKey]analysis.Fact)
+	pkgFacts := make(map[packageFactKey]analysis.Fact)
+	results := make(map[*analysis.Analyzer]interface{})
+	var run func(a *analysis.Analyzer) (interface{}, error)
+	run = func(a *analysis.Analyzer) (interface{}, error) {
+		if r, ok := results[a]; ok {
+			return r, nil
+		}
+		resultOf := make(map[*analysis.Analyzer]interface{})
+		for _, req := range a.Requires {
+			r, err := run(req)
+			if err != nil {
+				return nil, err
+			}
+			resultOf[req] = r
+		}
+		pass := &analysis.Pass{
+			Analyzer:   a,
+			Fset:       sp.Context.FileSet,
+			Files:      sp.Files,
+			Pkg:        sp.Package,
+			TypesInfo:  sp.Info,
+			TypesSizes: sp.Context.sizes,
+			ResultOf:   resultOf,
+			Report: func(d analysis.Diagnostic) {
+				category := d.Category
+				if category == "" {
+					category = a.Name
+				}
+				diags = append(diags, Diagnostic{
+					Pos:      sp.Context.FileSet.Position(d.Pos),
+					Category: category,
+					Message:  d.Message,
+				})
+			},
+			ImportObjectFact: func(obj types.Object, fact analysis.Fact) bool {
+				if f, ok := facts[objectFactKey{obj, reflect.TypeOf(fact)}]; ok {
+					reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(f).Elem())
+					return true
+				}
+				return false
+			},
+			ExportObjectFact: func(obj types.Object, fact analysis.Fact) {
+				facts[objectFactKey{obj, reflect.TypeOf(fact)}] = fact
+			},
+			ImportPackageFact: func(pkg *types.Package, fact analysis.Fact) bool {
+				if f, ok := pkgFacts[packageFactKey{pkg, reflect.TypeOf(fact)}]; ok {
+					reflect.ValueOf(fact).Elem().Set(reflect.ValueOf(f).Elem())
+					return true
+				}
+				return false
+			},
+			ExportPackageFact: func(fact analysis.Fact) {
+				pkgFacts[packageFactKey{sp.Package, reflect.TypeOf(fact)}] = fact
+			},
+			AllObjectFacts: func() (list []analysis.ObjectFact) {
+				for k, f := range facts {
+					list = append(list, analysis.ObjectFact{Object: k.obj, Fact: f})
+				}
+				return
+			},
+			AllPackageFacts: func() (list []analysis.PackageFact) {
+				for k, f := range pkgFacts {
+					list = append(list, analysis.PackageFact{Package: k.pkg, Fact: f})
+				}
+				return
+			},
+		}
+		r, err := a.Run(pass)
+		if err != nil {
+			return nil, fmt.Errorf("vet %v: %w", a.Name, err)
+		}
+		results[a] = r
+		return r, nil
+	}
+	for _, a := range vetAnalyzers {
+		if _, err := run(a); err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		pi, pj := diags[i].Pos, diags[j].Pos
+		if pi.Filename != pj.Filename {
+			return pi.Filename < pj.Filename
+		}
+		return pi.Offset < pj.Offset
+	})
+	sp.Context.diagnostics = append(sp.Context.diagnostics, diags...)
+	return nil
+}
+
+type objectFactKey struct {
+	obj types.Object
+	typ reflect.Type
+}
+
+type packageFactKey struct {
+	pkg *types.Package
+	typ reflect.Type
+}
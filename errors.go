@@ -23,12 +23,14 @@ import (
 )
 
 var (
-	ErrNotFoundMain    = errors.New("not found main package")
-	ErrTestFailed      = errors.New("test failed")
-	ErrNotFoundPackage = errors.New("not found package")
-	ErrGoexitDeadlock  = errors.New("fatal error: no goroutines (main called runtime.Goexit) - deadlock!")
-	ErrNoFunction      = errors.New("no function")
-	ErrNoTestFiles     = errors.New("[no test files]")
+	ErrNotFoundMain      = errors.New("not found main package")
+	ErrTestFailed        = errors.New("test failed")
+	ErrNotFoundPackage   = errors.New("not found package")
+	ErrGoexitDeadlock    = errors.New("fatal error: no goroutines (main called runtime.Goexit) - deadlock!")
+	ErrNoFunction        = errors.New("no function")
+	ErrNoTestFiles       = errors.New("[no test files]")
+	ErrTimeout           = errors.New("execution timed out")
+	ErrPackageNotAllowed = errors.New("package not allowed")
 )
 
 type ExitError int
@@ -69,18 +71,38 @@ func (p PanicError) Stack() []byte {
 	return p.stack
 }
 
+// Unwrap returns the panic value if it implements error, so errors.Is and
+// errors.As work on the interpreted error values.
+func (p PanicError) Unwrap() error {
+	if err, ok := p.Value.(error); ok {
+		return err
+	}
+	return nil
+}
+
 // run func fatal error
 type FatalError struct {
-	stack []byte
-	Value value
+	stack     []byte
+	hostStack []byte
+	Value     value
 }
 
 func (p FatalError) Error() string {
 	var buf bytes.Buffer
 	writeany(&buf, p.Value)
+	if p.hostStack != nil {
+		buf.WriteString("\n\n")
+		buf.Write(p.hostStack)
+	}
 	return buf.String()
 }
 
+// HostStack returns the host stack of the interpreter internal panic,
+// it is captured only when EnableHostPanicStack is set.
+func (p FatalError) HostStack() []byte {
+	return p.hostStack
+}
+
 func (p FatalError) Stack() []byte {
 	return p.stack
 }
@@ -89,3 +111,6 @@ func (p FatalError) Stack() []byte {
 type exitPanic int
 
 type goexitPanic int
+
+// If the interp is aborted, the blocked goroutines panic with this type.
+type abortPanic int
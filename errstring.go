@@ -25,6 +25,7 @@ const (
 	errDeclaredNotUsed     = "declared but not used"
 	errImportedNotUsed     = "imported but not used"
 	errAppendOutOfRange    = "growslice: cap out of range"
+	errGrowSliceOutOfRange = "growslice: cap out of range"
 	errSliceToArrayPointer = "cannot convert slice with length %v to pointer to array with length %v"
 )
 
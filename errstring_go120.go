@@ -25,6 +25,7 @@ const (
 	errDeclaredNotUsed     = "declared and not used"
 	errImportedNotUsed     = "imported and not used"
 	errAppendOutOfRange    = "len out of range"
+	errGrowSliceOutOfRange = "growslice: len out of range"
 	errSliceToArrayPointer = "cannot convert slice with length %v to array or pointer to array with length %v"
 )
 
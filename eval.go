@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+const evalFuncName = "__igop_eval__"
+
+// Eval type-checks and compiles expr against the main package scope and
+// evaluates it. expr may reference package-level identifiers of the main
+// package and call its functions.
+func (i *Interp) Eval(expr string) (Value, error) {
+	pkg := i.mainpkg.Pkg
+	src := fmt.Sprintf("package %v\n\nfunc %v() interface{} {\n\treturn %v\n}\n", pkg.Name(), evalFuncName, expr)
+	file, err := parser.ParseFile(i.ctx.FileSet, "", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	// new package shares the objects of main package scope
+	epkg := types.NewPackage(pkg.Path(), pkg.Name())
+	scope := pkg.Scope()
+	for _, name := range scope.Names() {
+		epkg.Scope().Insert(scope.Lookup(name))
+	}
+	info := newTypesInfo()
+	conf := &types.Config{
+		Sizes:    i.ctx.sizes,
+		Importer: NewImporter(i.ctx),
+	}
+	if err := types.NewChecker(conf, i.ctx.FileSet, epkg, info).Files([]*ast.File{file}); err != nil {
+		return nil, err
+	}
+	fn, err := i.buildEvalFunc(epkg, file, info)
+	if err != nil {
+		return nil, err
+	}
+	return i.runFunc(fn)
+}
+
+func (i *Interp) buildEvalFunc(pkg *types.Package, file *ast.File, info *types.Info) (fn *ssa.Function, err error) {
+	if i.ctx.Mode&DisableRecover == 0 {
+		defer func() {
+			if e := recover(); e != nil {
+				err = fmt.Errorf("build SSA package error: %v", e)
+			}
+		}()
+	}
+	prog := i.mainpkg.Prog
+	epkg := prog.CreatePackage(pkg, []*ast.File{file}, info, false)
+	epkg.Build()
+	fn = epkg.Func(evalFuncName)
+	return fn, checkFunction(i, epkg, fn)
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// FaultCall describes a call of external function checked by FaultPolicy.
+type FaultCall struct {
+	Name  string          // function full name, eg: os.Open
+	Count int             // call count of the function, start at 1
+	Args  []reflect.Value // call arguments
+}
+
+// FaultPolicy decides per-call how to inject fault to external function.
+// The call is delayed by delay. If err is not nil, the external function
+// is not called, its results are zero values and the last result is err
+// if it is error type, otherwise the call panics with err.
+type FaultPolicy func(call *FaultCall) (delay time.Duration, err error)
+
+type faultInjector struct {
+	policy FaultPolicy
+	count  int32
+}
+
+// InjectFault register fault injection policy for external function name.
+// A nil policy removes the injection.
+func (ctx *Context) InjectFault(name string, policy FaultPolicy) {
+	if policy == nil {
+		delete(ctx.faults, name)
+		return
+	}
+	if ctx.faults == nil {
+		ctx.faults = make(map[string]*faultInjector)
+	}
+	ctx.faults[name] = &faultInjector{policy: policy}
+}
+
+func (ctx *Context) wrapFault(name string, fn reflect.Value) reflect.Value {
+	inj, ok := ctx.faults[name]
+	if !ok {
+		return fn
+	}
+	typ := fn.Type()
+	numOut := typ.NumOut()
+	hasError := numOut > 0 && typ.Out(numOut-1) == tyErrorInterface
+	return reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+		call := &FaultCall{
+			Name:  name,
+			Count: int(atomic.AddInt32(&inj.count, 1)),
+			Args:  args,
+		}
+		if typ.NumIn() > 0 && typ.In(0) == typFramePtr {
+			call.Args = args[1:]
+		}
+		delay, err := inj.policy(call)
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		if err == nil {
+			if typ.IsVariadic() {
+				return fn.CallSlice(args)
+			}
+			return fn.Call(args)
+		}
+		if !hasError {
+			panic(err)
+		}
+		results := make([]reflect.Value, numOut)
+		for i := 0; i < numOut-1; i++ {
+			results[i] = reflect.New(typ.Out(i)).Elem()
+		}
+		results[numOut-1] = reflect.ValueOf(&err).Elem()
+		return results
+	})
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SetFileSystem set the file system used by the os and io/ioutil package file
+// operations of interpreted programs. Read operations are served from fsys and
+// write operations fail with fs.ErrPermission. os.Open and os.OpenFile return an
+// *os.File, they succeed only if fsys opens files as *os.File (e.g. os.DirFS),
+// otherwise they fail with fs.ErrPermission. A nil fsys restores the host file
+// system and the overrides of these functions registered before.
+func (ctx *Context) SetFileSystem(fsys fs.FS) {
+	if fsys == nil {
+		if ctx.fsRestore != nil {
+			ctx.fsRestore()
+			ctx.fsRestore = nil
+		}
+		return
+	}
+	funcs := make(map[string]reflect.Value)
+	for name, fn := range fileSystemFuncs(fsys) {
+		funcs[name] = reflect.ValueOf(fn)
+	}
+	if ctx.fsRestore == nil {
+		ctx.fsRestore = ctx.replaceOverride(funcs)
+	} else {
+		for name, fn := range funcs {
+			ctx.override[name] = fn
+		}
+	}
+}
+
+// fsName convert os file name to fs.FS name
+func fsName(name string) string {
+	name = strings.TrimPrefix(path.Clean(filepath.ToSlash(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func fsOpen(fsys fs.FS, name string) (*os.File, error) {
+	f, err := fsys.Open(fsName(name))
+	if err != nil {
+		return nil, err
+	}
+	if file, ok := f.(*os.File); ok {
+		return file, nil
+	}
+	f.Close()
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+// errFS is the fs.FS of os.DirFS with a dir outside the file system.
+type errFS struct {
+	err error
+}
+
+func (e errFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: e.err}
+}
+
+func fileSystemFuncs(fsys fs.FS) map[string]interface{} {
+	denied := func(op string, name string) error {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	deniedLink := func(op string, oldname, newname string) error {
+		return &os.LinkError{Op: op, Old: oldname, New: newname, Err: fs.ErrPermission}
+	}
+	return map[string]interface{}{
+		"os.ReadFile": func(name string) ([]byte, error) {
+			return fs.ReadFile(fsys, fsName(name))
+		},
+		"os.ReadDir": func(name string) ([]fs.DirEntry, error) {
+			return fs.ReadDir(fsys, fsName(name))
+		},
+		"os.Stat": func(name string) (fs.FileInfo, error) {
+			return fs.Stat(fsys, fsName(name))
+		},
+		"os.Lstat": func(name string) (fs.FileInfo, error) {
+			return fs.Stat(fsys, fsName(name))
+		},
+		"os.Open": func(name string) (*os.File, error) {
+			return fsOpen(fsys, name)
+		},
+		"os.OpenFile": func(name string, flag int, perm fs.FileMode) (*os.File, error) {
+			if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0 {
+				return nil, denied("open", name)
+			}
+			return fsOpen(fsys, name)
+		},
+		"os.Create": func(name string) (*os.File, error) {
+			return nil, denied("open", name)
+		},
+		"os.WriteFile": func(name string, data []byte, perm fs.FileMode) error {
+			return denied("open", name)
+		},
+		"os.Mkdir": func(name string, perm fs.FileMode) error {
+			return denied("mkdir", name)
+		},
+		"os.MkdirAll": func(name string, perm fs.FileMode) error {
+			return denied("mkdir", name)
+		},
+		"os.Remove": func(name string) error {
+			return denied("remove", name)
+		},
+		"os.RemoveAll": func(name string) error {
+			return denied("unlinkat", name)
+		},
+		"os.Rename": func(oldpath, newpath string) error {
+			return deniedLink("rename", oldpath, newpath)
+		},
+		"os.Chmod": func(name string, mode fs.FileMode) error {
+			return denied("chmod", name)
+		},
+		"os.Chown": func(name string, uid, gid int) error {
+			return denied("chown", name)
+		},
+		"os.Lchown": func(name string, uid, gid int) error {
+			return denied("lchown", name)
+		},
+		"os.Chtimes": func(name string, atime time.Time, mtime time.Time) error {
+			return denied("chtimes", name)
+		},
+		"os.Chdir": func(dir string) error {
+			return denied("chdir", dir)
+		},
+		"os.Truncate": func(name string, size int64) error {
+			return denied("truncate", name)
+		},
+		"os.Readlink": func(name string) (string, error) {
+			return "", denied("readlink", name)
+		},
+		"os.Symlink": func(oldname, newname string) error {
+			return deniedLink("symlink", oldname, newname)
+		},
+		"os.Link": func(oldname, newname string) error {
+			return deniedLink("link", oldname, newname)
+		},
+		"os.CreateTemp": func(dir, pattern string) (*os.File, error) {
+			return nil, denied("createtemp", dir)
+		},
+		"os.MkdirTemp": func(dir, pattern string) (string, error) {
+			return "", denied("mkdirtemp", dir)
+		},
+		"os.CopyFS": func(dir string, fsys fs.FS) error {
+			return denied("mkdir", dir)
+		},
+		"os.DirFS": func(dir string) fs.FS {
+			sub, err := fs.Sub(fsys, fsName(dir))
+			if err != nil {
+				return errFS{err}
+			}
+			return sub
+		},
+		"io/ioutil.ReadFile": func(filename string) ([]byte, error) {
+			return fs.ReadFile(fsys, fsName(filename))
+		},
+		"io/ioutil.ReadDir": func(dirname string) ([]fs.FileInfo, error) {
+			entries, err := fs.ReadDir(fsys, fsName(dirname))
+			if err != nil {
+				return nil, err
+			}
+			infos := make([]fs.FileInfo, 0, len(entries))
+			for _, entry := range entries {
+				info, err := entry.Info()
+				if err != nil {
+					return nil, err
+				}
+				infos = append(infos, info)
+			}
+			return infos, nil
+		},
+		"io/ioutil.WriteFile": func(filename string, data []byte, perm fs.FileMode) error {
+			return denied("open", filename)
+		},
+		"io/ioutil.TempFile": func(dir, pattern string) (*os.File, error) {
+			return nil, denied("createtemp", dir)
+		},
+		"io/ioutil.TempDir": func(dir, pattern string) (string, error) {
+			return "", denied("mkdirtemp", dir)
+		},
+	}
+}
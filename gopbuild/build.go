@@ -110,7 +110,7 @@ func BuildFSDir(ctx *igop.Context, fs parser.FileSystem, dir string) (data []byt
 	defer func() {
 		r := recover()
 		if r != nil {
-			err = fmt.Errorf("compile %v failed. %v", dir, err)
+			err = fmt.Errorf("compile %v failed. %v", dir, r)
 		}
 	}()
 	c := NewContext(ctx)
@@ -125,7 +125,7 @@ func BuildDir(ctx *igop.Context, dir string) (data []byte, err error) {
 	defer func() {
 		r := recover()
 		if r != nil {
-			err = fmt.Errorf("compile %v failed. %v", dir, err)
+			err = fmt.Errorf("compile %v failed. %v", dir, r)
 		}
 	}()
 	c := NewContext(ctx)
@@ -17,12 +17,15 @@
 package gopbuild
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/goplus/igop"
 	_ "github.com/goplus/igop/pkg/bytes"
+	_ "github.com/goplus/igop/pkg/fmt"
+	_ "github.com/goplus/igop/pkg/reflect"
 )
 
 func gopClTest(t *testing.T, gopcode, expected string) {
@@ -551,3 +554,44 @@ func main() {
 }
 `)
 }
+
+func TestRunGopFile(t *testing.T) {
+	ctx := igop.NewContext(0)
+	var buf bytes.Buffer
+	if err := ctx.SetOutput(&buf); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ctx.RunGopFile("main.gop", `
+println "a".repeat(3), [x*x for x <- [1, 2, 3]]
+`, nil)
+	ctx.SetOutput(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "aaa [1 4 9]\n" {
+		t.Fatalf("output %q", s)
+	}
+	// classfile
+	_, err = igop.NewContext(0).RunGopFile("Rect.gox", `
+var (
+	w, h int
+)
+
+func Area() int {
+	return w * h
+}
+
+if Area() != 0 {
+	panic "area"
+}
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = igop.NewContext(0).RunGopFile("main.gop", `
+println "Go+" +
+`, nil)
+	if err == nil {
+		t.Fatal("must report Go+ syntax error")
+	}
+}
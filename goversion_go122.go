@@ -0,0 +1,34 @@
+//go:build go1.22
+// +build go1.22
+
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// setGoVersion set the language version of types check, and record the file
+// versions used by ssa builder, e.g. the per-iteration loop variables of go1.22.
+func setGoVersion(conf *types.Config, info *types.Info, version string) {
+	conf.GoVersion = version
+	if info.FileVersions == nil {
+		info.FileVersions = make(map[*ast.File]string)
+	}
+}
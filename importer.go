@@ -23,10 +23,11 @@ import (
 )
 
 type Importer struct {
-	ctx         *Context
-	pkgs        map[string]*types.Package
-	importing   map[string]bool
-	defaultImpl types.Importer
+	ctx          *Context
+	pkgs         map[string]*types.Package
+	importing    map[string]bool
+	defaultImpl  types.Importer
+	unrestricted bool // skip the package whitelist for register package source
 }
 
 func NewImporter(ctx *Context) *Importer {
@@ -42,6 +43,9 @@ func (i *Importer) Import(path string) (*types.Package, error) {
 	if pkg, ok := i.pkgs[path]; ok {
 		return pkg, nil
 	}
+	if !i.unrestricted && !i.ctx.allowImport(path) {
+		return nil, fmt.Errorf("import %q: %w", path, ErrPackageNotAllowed)
+	}
 	if i.importing[path] {
 		return nil, fmt.Errorf("cycle importing package %q", path)
 	}
@@ -74,3 +78,13 @@ func (i *Importer) Import(path string) (*types.Package, error) {
 	}
 	return nil, ErrNotFoundPackage
 }
+
+// allowImport reports whether path is importable by the package whitelist,
+// the custom builtin package and its dependencies are always allowed.
+func (ctx *Context) allowImport(path string) bool {
+	if ctx.whitelist == nil || ctx.whitelist[path] || path == builtinPkg.Path {
+		return true
+	}
+	_, ok := builtinPkg.Deps[path]
+	return ok
+}
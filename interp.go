@@ -23,10 +23,10 @@
 // * The "testing" package is no longer supported because it
 // depends on low-level details that change too often.
 //
-// * "sync/atomic" operations are not atomic due to the "boxed" value
-// representation: it is not possible to read, modify and write an
-// interface value atomically. As a consequence, Mutexes are currently
-// broken.
+// * variables are allocated as real typed memory (Alloc, FieldAddr and
+// IndexAddr yield host pointers), so "sync" and "sync/atomic" operate on
+// a stable address. Copying a struct containing a sync.Mutex copies the
+// lock state, as in Go.
 //
 // * recover is only partially implemented.  Also, the interpreter
 // makes no attempt to distinguish target panics from interpreter
@@ -34,7 +34,8 @@
 //
 // * the sizes of the int, uint and uintptr types in the target
 // program are assumed to be the same as those of the interpreter
-// itself.
+// itself, unless Context.SetIntSize(32) is used to simulate a 32-bit
+// target.
 //
 // * all values occupy space, even those of types defined by the spec
 // to have zero size, e.g. struct{}.  This can cause asymptotic
@@ -46,12 +47,15 @@
 package igop
 
 import (
+	"flag"
 	"fmt"
 	"go/ast"
 	"go/constant"
 	"go/token"
 	"go/types"
+	"os"
 	"reflect"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -70,9 +74,11 @@ var (
 
 const intSize = 32 << (^uint(0) >> 63)
 
+const maxMemLen32 = 1<<31 - 1
+
 func init() {
 	if intSize == 32 {
-		maxMemLen = 1<<31 - 1
+		maxMemLen = maxMemLen32
 	} else {
 		v := int64(1) << 59
 		maxMemLen = int(v)
@@ -80,17 +86,21 @@ func init() {
 }
 
 type Interp struct {
+	allocs       AllocStats // interpreted allocation stats, atomically updated, must 64-bit aligned
 	ctx          *Context
 	mainpkg      *ssa.Package                                // the SSA main package
 	record       *TypesRecord                                // lookup type and ToType
 	globals      map[string]value                            // addresses of global variables (immutable)
+	vars         []reflect.Value                             // interpreted global variables, zeroed by ResetForReuse
 	chkinit      map[string]bool                             // init vars
 	preloadTypes map[types.Type]reflect.Type                 // preload types.Type -> reflect.Type
 	funcs        map[*ssa.Function]*function                 // ssa.Function -> *function
 	msets        map[reflect.Type](map[string]*ssa.Function) // user defined type method sets
 	chexit       chan int                                    // call os.Exit code by chan for runtime.Goexit
-	cherror      chan PanicError                             // call by go func error for context
+	cherror      chan PanicError                             // panic not recovered by go func
+	done         chan struct{}                               // closed by Abort
 	deferMap     sync.Map                                    // defer goroutine id -> call frame
+	goids        sync.Map                                    // ids of goroutines started by go statement
 	rfuncMap     sync.Map                                    // reflect.Value(fn).Pointer -> *function
 	typesMutex   sync.RWMutex                                // findType/toType mutex
 	mainid       int64                                       // main goroutine id
@@ -99,6 +109,11 @@ type Interp struct {
 	deferCount   int32                                       // fast has defer check
 	goexited     int32                                       // is call runtime.Goexit
 	exited       int32                                       // is call os.Exit
+	noUnexported bool                                        // panic on access unexported members
+	args         []string                                    // os.Args of isolated interp
+	flags        *flag.FlagSet                               // flag.CommandLine of isolated interp
+	usage        func()                                      // flag.Usage of isolated interp
+	signals      signalTable                                 // virtual signal delivery
 }
 
 func (i *Interp) MainPkg() *ssa.Package {
@@ -545,13 +560,22 @@ func (fr *frame) runDefers() {
 	interp := fr.interp
 	atomic.AddInt32(&interp.deferCount, 1)
 	fr.deferid = goroutineID()
+	// restore the outer defer frame of this goroutine, also on
+	// runtime.Goexit unwinding past the deferred calls.
+	outer, nested := interp.deferMap.Load(fr.deferid)
 	interp.deferMap.Store(fr.deferid, fr)
+	defer func() {
+		if nested {
+			interp.deferMap.Store(fr.deferid, outer)
+		} else {
+			interp.deferMap.Delete(fr.deferid)
+		}
+		atomic.AddInt32(&interp.deferCount, -1)
+		fr.deferid = 0
+	}()
 	for d := fr._defer; d != nil; d = d.tail {
 		fr.runDefer(d)
 	}
-	interp.deferMap.Delete(fr.deferid)
-	atomic.AddInt32(&interp.deferCount, -1)
-	fr.deferid = 0
 	fr._defer = nil
 	// runtime.Goexit() fr.panic == nil
 	if !fr._panic.isNil() {
@@ -642,6 +666,9 @@ func (i *Interp) prepareCall(fr *frame, call *ssa.CallCommon, iv register, ia []
 		}
 	} else {
 		v := fr.reg(iv)
+		if v == nil {
+			panic(RuntimeError("invalid memory address or nil pointer dereference"))
+		}
 		rtype := reflect.TypeOf(v)
 		mname := call.Method.Name()
 		if mset, ok := i.msets[rtype]; ok {
@@ -888,6 +915,10 @@ func (i *Interp) callExternal(caller *frame, fn reflect.Value, args []value, env
 	}
 	var ins []reflect.Value
 	typ := fn.Type()
+	if typ.NumIn() > 0 && typ.In(0) == typFramePtr {
+		// deferred or go call of the external func with frame
+		args = append([]value{caller}, args...)
+	}
 	isVariadic := fn.Type().IsVariadic()
 	if isVariadic {
 		for i := 0; i < len(args)-1; i++ {
@@ -933,6 +964,10 @@ func (i *Interp) callExternalDiscardsResult(caller *frame, fn reflect.Value, arg
 	}
 	var ins []reflect.Value
 	typ := fn.Type()
+	if typ.NumIn() > 0 && typ.In(0) == typFramePtr {
+		// deferred or go call of the external func with frame
+		args = append([]value{caller}, args...)
+	}
 	isVariadic := fn.Type().IsVariadic()
 	if isVariadic {
 		for i := 0; i < len(args)-1; i++ {
@@ -1078,7 +1113,14 @@ func (fr *frame) run() {
 			if fr.ipc == -1 || fr._defer == nil {
 				return // normal return
 			}
-			fr._panic = &_panic{arg: recover()}
+			p := recover()
+			if p == nil {
+				// the host runtime.Goexit (e.g. testing.T.FailNow) is not
+				// a panic, run the deferred calls and continue to exit.
+				fr.runDefers()
+				return
+			}
+			fr._panic = &_panic{arg: p}
 			callee := fr.callee
 			for callee.aborted() {
 				if !callee._panic.isNil() {
@@ -1123,6 +1165,11 @@ func doRecover(caller *frame) value {
 		caller._panic.isNil() &&
 		caller.caller != nil && !caller.caller._panic.isNil() {
 		p := caller.caller._panic.arg
+		// runtime.Goexit and abort are not panics and cannot be recovered.
+		switch p.(type) {
+		case goexitPanic, abortPanic:
+			return nil
+		}
 		caller.caller._panic.recovered = true
 		switch p := p.(type) {
 		case PanicError:
@@ -1151,6 +1198,10 @@ func NewInterp(ctx *Context, mainpkg *ssa.Package) (*Interp, error) {
 }
 
 func newInterp(ctx *Context, mainpkg *ssa.Package, globals map[string]interface{}) (*Interp, error) {
+	if err := ctx.openOutput(); err != nil {
+		return nil, err
+	}
+	ctx.resetRand()
 	i := &Interp{
 		ctx:          ctx,
 		mainpkg:      mainpkg,
@@ -1161,8 +1212,13 @@ func newInterp(ctx *Context, mainpkg *ssa.Package, globals map[string]interface{
 		funcs:        make(map[*ssa.Function]*function),
 		msets:        make(map[reflect.Type](map[string]*ssa.Function)),
 		chexit:       make(chan int),
+		cherror:      make(chan PanicError, 1),
+		done:         make(chan struct{}),
 		mainid:       goroutineID(),
 	}
+	if i.isolated() {
+		i.setArgs(append([]string(nil), os.Args...))
+	}
 	var rctx *reflectx.Context
 	if ctx.Mode&SupportMultipleInterp == 0 {
 		reflectx.ResetAll()
@@ -1191,7 +1247,9 @@ func newInterp(ctx *Context, mainpkg *ssa.Package, globals map[string]interface{
 					i.globals[key] = ext.Interface()
 					i.chkinit[key] = true
 				} else {
-					i.globals[key] = reflect.New(typ).Interface()
+					v := reflect.New(typ)
+					i.globals[key] = v.Interface()
+					i.vars = append(i.vars, v.Elem())
 				}
 			}
 		}
@@ -1271,6 +1329,15 @@ func (i *Interp) toType(typ types.Type) reflect.Type {
 }
 
 func (i *Interp) RunFunc(name string, args ...Value) (r Value, err error) {
+	fn := i.mainpkg.Func(name)
+	if fn == nil {
+		return nil, fmt.Errorf("no function %v", name)
+	}
+	defer i.ctx.flushOutput()
+	return i.runFunc(fn, args...)
+}
+
+func (i *Interp) runFunc(fn *ssa.Function, args ...Value) (r Value, err error) {
 	fr := &frame{interp: i}
 	defer func() {
 		if i.ctx.Mode&DisableRecover != 0 {
@@ -1281,34 +1348,56 @@ func (i *Interp) RunFunc(name string, args ...Value) (r Value, err error) {
 			// nothing
 		case exitPanic:
 			i.exitCode = int(p)
-			atomic.StoreInt32(&i.exited, 1)
+			i.Abort()
+		case abortPanic:
+			// aborted by Abort or goroutine panic
 		case goexitPanic:
 			// check goroutines
 			if atomic.LoadInt32(&i.goroutines) == 1 {
 				err = ErrGoexitDeadlock
 			} else {
-				i.exitCode = <-i.chexit
-				atomic.StoreInt32(&i.exited, 1)
+				select {
+				case i.exitCode = <-i.chexit:
+					i.Abort()
+				case <-i.done:
+				}
 			}
 		case PanicError:
+			if filter := i.ctx.panicFilter; filter != nil {
+				v, handled := filter(p.Value)
+				if handled {
+					return
+				}
+				p.Value = v
+			}
 			err = p
 		default:
 			// runtimeError / plainError ...
+			var v value = p
+			if filter := i.ctx.panicFilter; filter != nil {
+				var handled bool
+				if v, handled = filter(p); handled {
+					return
+				}
+			}
 			pfr := fr
 			for pfr.callee != nil {
 				pfr = pfr.callee
 			}
-			err = FatalError{stack: debugStack(pfr), Value: p}
+			fe := FatalError{stack: debugStack(pfr), Value: v}
+			if i.ctx.Mode&EnableHostPanicStack != 0 {
+				// interpreter internal panic, not a target runtime error
+				if _, ok := p.(interface{ RuntimeError() }); !ok {
+					fe.hostStack = debug.Stack()
+				}
+			}
+			err = fe
 			if i.ctx.panicFunc != nil {
-				i.ctx.handlePanic(fr, i.mainpkg.Func(name), err)
+				i.ctx.handlePanic(fr, fn, err)
 			}
 		}
 	}()
-	if fn := i.mainpkg.Func(name); fn != nil {
-		r = i.call(fr, fn, args, nil)
-	} else {
-		err = fmt.Errorf("no function %v", name)
-	}
+	r = i.call(fr, fn, args, nil)
 	return
 }
 
@@ -1319,11 +1408,47 @@ func (i *Interp) ExitCode() int {
 func (i *Interp) RunInit() (err error) {
 	i.goexited = 0
 	i.exitCode = 0
-	atomic.StoreInt32(&i.exited, 0)
+	if atomic.SwapInt32(&i.exited, 0) == 1 {
+		i.done = make(chan struct{})
+	}
+	i.mainid = goroutineID()
+	for key, value := range i.ctx.linkValues {
+		if err = i.setGlobal(key, value); err != nil {
+			return
+		}
+	}
 	_, err = i.RunFunc("init")
 	return
 }
 
+// ResetForReuse zero the interpreted global variables and run the package
+// initialization again, then the interp can run main again without rebuilding.
+// The pending output of the previous run is written before the initialization,
+// the state of the host packages is not reset.
+func (i *Interp) ResetForReuse() error {
+	// write the pending output of the previous run
+	i.ctx.flushOutput()
+	for _, v := range i.vars {
+		v.Set(reflect.Zero(v.Type()))
+	}
+	return i.RunInit()
+}
+
+// setGlobal set the global variable key to v, skip if not found.
+func (i *Interp) setGlobal(key string, v value) error {
+	p, ok := i.globals[key]
+	if !ok {
+		return nil
+	}
+	elem := reflect.ValueOf(p).Elem()
+	x := reflect.ValueOf(v)
+	if elem.Kind() != x.Kind() {
+		return fmt.Errorf("cannot set %v of type %v to %v", key, elem.Type(), x.Type())
+	}
+	SetValue(elem, x)
+	return nil
+}
+
 // ResetAllIcall is reset all reflectx icall, all interp methods invalid.
 func ResetAllIcall() {
 	reflectx.ResetAll()
@@ -1357,15 +1482,98 @@ func (i *Interp) UnsafeRelease() {
 	i.record = nil
 }
 
+// Abort stops the interpreted program, the goroutines blocked on channel
+// operations are unblocked and end.
 func (i *Interp) Abort() {
-	atomic.StoreInt32(&i.exited, 1)
+	if atomic.CompareAndSwapInt32(&i.exited, 0, 1) {
+		close(i.done)
+	}
+}
+
+// chanRecv receives a value from ch, the blocked receive ends by Abort.
+func (i *Interp) chanRecv(ch reflect.Value) (reflect.Value, bool) {
+	if v, ok := ch.TryRecv(); v.IsValid() {
+		return v, ok
+	}
+	chosen, v, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(i.done)},
+	})
+	if chosen == 1 {
+		panic(abortPanic(0))
+	}
+	return v, ok
+}
+
+// chanSend sends x to ch, the blocked send ends by Abort.
+func (i *Interp) chanSend(ch reflect.Value, x reflect.Value) {
+	if ch.TrySend(x) {
+		return
+	}
+	chosen, _, _ := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: ch, Send: x},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(i.done)},
+	})
+	if chosen == 1 {
+		panic(abortPanic(0))
+	}
+}
+
+// goPanic reports the panic not recovered by an interpreted goroutine and
+// terminates the program, as the gc runtime does.
+func (i *Interp) goPanic(e PanicError) {
+	if v, ok := e.Value.(exitPanic); ok {
+		i.exitCode = int(v)
+	} else if filter := i.ctx.panicFilter; filter != nil {
+		v, handled := filter(e.Value)
+		if handled {
+			return
+		}
+		e.Value = v
+	}
+	select {
+	case i.cherror <- e:
+	default:
+	}
+	i.Abort()
 }
 
 func (i *Interp) RunMain() (exitCode int, err error) {
+	return i.RunEntry("main")
+}
+
+// RunEntry run the entry function name of the main package as the program
+// entry, it must have no parameters and results.
+func (i *Interp) RunEntry(name string) (exitCode int, err error) {
 	if atomic.LoadInt32(&i.exited) == 1 {
 		return i.exitCode, nil
 	}
-	_, err = i.RunFunc("main")
+	fn := i.mainpkg.Func(name)
+	if fn == nil {
+		return 2, fmt.Errorf("no function %v", name)
+	}
+	if sig := fn.Signature; sig.Params().Len() != 0 || sig.Results().Len() != 0 || sig.Recv() != nil {
+		return 2, fmt.Errorf("entry function %v must be func(), not %v", name, sig)
+	}
+	// run the entry in its own goroutine, a panic in other goroutines is
+	// reported at once even if the entry is blocked.
+	ch := make(chan error, 1)
+	go func() {
+		i.mainid = goroutineID()
+		_, err := i.RunFunc(name)
+		ch <- err
+	}()
+	defer i.ctx.flushOutput()
+	select {
+	case err = <-ch:
+	case e := <-i.cherror:
+		return i.panicExit(e)
+	}
+	select {
+	case e := <-i.cherror:
+		return i.panicExit(e)
+	default:
+	}
 	if err != nil {
 		exitCode = 2
 	}
@@ -1375,8 +1583,64 @@ func (i *Interp) RunMain() (exitCode int, err error) {
 	return
 }
 
-func (i *Interp) GetFunc(key string) (interface{}, bool) {
-	m, ok := i.mainpkg.Members[key]
+// panicExit returns the exit code and error of the goroutine panic e.
+func (i *Interp) panicExit(e PanicError) (int, error) {
+	if v, ok := e.Value.(exitPanic); ok {
+		return int(v), nil
+	}
+	return 2, e
+}
+
+// SetPanicOnUnexported set whether GetFunc/GetVarAddr/GetConst/GetType panic on
+// access the unexported members of main package, and GetSymbol not found
+// unexported members. Default allow access unexported members.
+func (i *Interp) SetPanicOnUnexported(b bool) {
+	i.noUnexported = b
+}
+
+func (i *Interp) checkExported(m ssa.Member) {
+	if i.noUnexported && !token.IsExported(m.Name()) {
+		panic(fmt.Errorf("access unexported member %v.%v", m.Package().Pkg.Path(), m.Name()))
+	}
+}
+
+// PackageAccessor is the accessor of the members of an interpreted package.
+type PackageAccessor struct {
+	interp *Interp
+	pkg    *ssa.Package
+}
+
+// PackageByPath returns the accessor of the package path interpreted from
+// source, e.g. an imported package of the main package.
+func (i *Interp) PackageByPath(path string) (*PackageAccessor, bool) {
+	if i.mainpkg.Pkg.Path() == path {
+		return &PackageAccessor{i, i.mainpkg}, true
+	}
+	if _, ok := i.ctx.pkgs[path]; !ok {
+		return nil, false
+	}
+	pkg := i.mainpkg.Prog.ImportedPackage(path)
+	if pkg == nil {
+		return nil, false
+	}
+	return &PackageAccessor{i, pkg}, true
+}
+
+// Path returns the package path.
+func (p *PackageAccessor) Path() string {
+	return p.pkg.Pkg.Path()
+}
+
+func (p *PackageAccessor) lookupMember(key string) (ssa.Member, bool) {
+	m, ok := p.pkg.Members[key]
+	if ok {
+		p.interp.checkExported(m)
+	}
+	return m, ok
+}
+
+func (p *PackageAccessor) GetFunc(key string) (interface{}, bool) {
+	m, ok := p.lookupMember(key)
 	if !ok {
 		return nil, false
 	}
@@ -1384,11 +1648,15 @@ func (i *Interp) GetFunc(key string) (interface{}, bool) {
 	if !ok {
 		return nil, false
 	}
-	return i.funcs[fn].makeFunction(i.toType(fn.Type()), nil).Interface(), true
+	pfn, ok := p.interp.funcs[fn]
+	if !ok {
+		return nil, false
+	}
+	return pfn.makeFunction(p.interp.toType(fn.Type()), nil).Interface(), true
 }
 
-func (i *Interp) GetVarAddr(key string) (interface{}, bool) {
-	m, ok := i.mainpkg.Members[key]
+func (p *PackageAccessor) GetVarAddr(key string) (interface{}, bool) {
+	m, ok := p.lookupMember(key)
 	if !ok {
 		return nil, false
 	}
@@ -1396,12 +1664,12 @@ func (i *Interp) GetVarAddr(key string) (interface{}, bool) {
 	if !ok {
 		return nil, false
 	}
-	p, ok := i.globals[v.String()]
-	return p, ok
+	ptr, ok := p.interp.globals[v.String()]
+	return ptr, ok
 }
 
-func (i *Interp) GetConst(key string) (constant.Value, bool) {
-	m, ok := i.mainpkg.Members[key]
+func (p *PackageAccessor) GetConst(key string) (constant.Value, bool) {
+	m, ok := p.lookupMember(key)
 	if !ok {
 		return nil, false
 	}
@@ -1412,8 +1680,8 @@ func (i *Interp) GetConst(key string) (constant.Value, bool) {
 	return v.Value.Value, true
 }
 
-func (i *Interp) GetType(key string) (reflect.Type, bool) {
-	m, ok := i.mainpkg.Members[key]
+func (p *PackageAccessor) GetType(key string) (reflect.Type, bool) {
+	m, ok := p.lookupMember(key)
 	if !ok {
 		return nil, false
 	}
@@ -1421,13 +1689,29 @@ func (i *Interp) GetType(key string) (reflect.Type, bool) {
 	if !ok {
 		return nil, false
 	}
-	return i.toType(t.Type()), true
+	return p.interp.toType(t.Type()), true
+}
+
+func (i *Interp) GetFunc(key string) (interface{}, bool) {
+	return (&PackageAccessor{i, i.mainpkg}).GetFunc(key)
+}
+
+func (i *Interp) GetVarAddr(key string) (interface{}, bool) {
+	return (&PackageAccessor{i, i.mainpkg}).GetVarAddr(key)
+}
+
+func (i *Interp) GetConst(key string) (constant.Value, bool) {
+	return (&PackageAccessor{i, i.mainpkg}).GetConst(key)
+}
+
+func (i *Interp) GetType(key string) (reflect.Type, bool) {
+	return (&PackageAccessor{i, i.mainpkg}).GetType(key)
 }
 
 func (i *Interp) GetSymbol(key string) (m ssa.Member, v interface{}, ok bool) {
 	defer func() {
-		if v := recover(); v != nil {
-			ok = false
+		if r := recover(); r != nil {
+			m, v, ok = nil, nil, false
 		}
 	}()
 	ar := strings.Split(key, ".")
@@ -1450,10 +1734,12 @@ func (i *Interp) GetSymbol(key string) (m ssa.Member, v interface{}, ok bool) {
 	default:
 		return
 	}
-	m, ok = pkg.Members[key]
-	if !ok {
+	member, found := pkg.Members[key]
+	if !found {
 		return
 	}
+	i.checkExported(member)
+	m, ok = member, true
 	switch p := m.(type) {
 	case *ssa.NamedConst:
 		v = p.Value.Value
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"testing"
+)
+
+func TestDeferMapCleanup(t *testing.T) {
+	src := `package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+func recovered(i int) (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	defer func() {
+		panic(i) // re-panic in deferred call
+	}()
+	panic("first")
+}
+
+func main() {
+	for i := 0; i < 1000; i++ {
+		if r := recovered(i); r != i {
+			panic(r)
+		}
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				recover()
+			}()
+			defer runtime.Goexit()
+			panic("goexit")
+		}()
+	}
+	wg.Wait()
+}
+`
+	ctx := NewContext(0)
+	pkg, err := ctx.LoadFile("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.NewInterp(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = interp.RunMain(); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	interp.deferMap.Range(func(k, v interface{}) bool {
+		n++
+		return true
+	})
+	if n != 0 {
+		t.Fatalf("deferMap leak %v entries", n)
+	}
+	if c := interp.deferCount; c != 0 {
+		t.Fatalf("deferCount %v, want 0", c)
+	}
+}
@@ -21,6 +21,7 @@ package igop_test
 
 import (
 	"runtime"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -195,3 +196,71 @@ func init() {
 		t.Fatal(err)
 	}
 }
+
+func TestGrowSliceOutOfRange(t *testing.T) {
+	if strconv.IntSize != 64 {
+		t.Skip("skip on 32-bit")
+	}
+	src := `package main
+
+import (
+	"strings"
+	"unsafe"
+)
+
+func main() {
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			panic("must error")
+		}
+		if s := err.Error(); !strings.HasPrefix(s, "runtime error: growslice: ") {
+			panic(s)
+		}
+	}()
+	var x int64
+	s := unsafe.Slice(&x, 1<<58)
+	s = append(s, 1)
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReflectVisibleFields(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Base struct {
+	ID   int64
+	Name string
+}
+
+type T struct {
+	Base
+	Name string ` + "`json:\"name\"`" + `
+	age  int
+}
+
+func main() {
+	var infos []string
+	for _, f := range reflect.VisibleFields(reflect.TypeOf(T{})) {
+		infos = append(infos, fmt.Sprintf("%v%v:%v:%v", f.Name, f.Index, f.Anonymous, f.IsExported()))
+	}
+	if s := fmt.Sprint(infos); s != "[Base[0]:true:true ID[0 0]:false:true Name[1]:false:true age[2]:false:false]" {
+		panic(s)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,111 @@
+//go:build go1.22
+// +build go1.22
+
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goplus/igop"
+)
+
+func TestLoopVarGoVersion(t *testing.T) {
+	src := `package main
+
+func main() {
+	var fns []func() int
+	for i := 0; i < 3; i++ {
+		fns = append(fns, func() int { return i })
+	}
+	var sum int
+	for _, fn := range fns {
+		sum += fn()
+	}
+	println(sum)
+}
+`
+	for _, v := range []struct {
+		version string
+		output  string
+	}{
+		{"go1.21", "9\n"},
+		{"go1.22", "3\n"},
+		{"", "3\n"},
+	} {
+		ctx := igop.NewContext(0)
+		ctx.SetGoVersion(v.version)
+		var buf bytes.Buffer
+		ctx.SetPrintOutput(&buf)
+		_, err := ctx.RunFile("main.go", src, nil)
+		if err != nil {
+			t.Fatal(v.version, err)
+		}
+		if s := buf.String(); s != v.output {
+			t.Fatalf("%v: got %q, want %q", v.version, s, v.output)
+		}
+	}
+}
+
+func TestRangeOverInt(t *testing.T) {
+	src := `package main
+
+type N int
+
+func main() {
+	var s []int
+	for i := range 5 {
+		s = append(s, i)
+	}
+	if len(s) != 5 || s[0] != 0 || s[4] != 4 {
+		panic(s)
+	}
+	n := 0
+	for range 3 {
+		n++
+	}
+	if n != 3 {
+		panic(n)
+	}
+	var last N
+	for i := range N(4) {
+		last = i
+	}
+	if last != 3 {
+		panic(last)
+	}
+	for i := range -1 {
+		panic(i)
+	}
+	var fns []func() int
+	for i := range 3 {
+		fns = append(fns, func() int { return i })
+	}
+	if fns[0]() != 0 || fns[2]() != 2 {
+		panic("per-iteration variable")
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetGoVersion("go1.22")
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
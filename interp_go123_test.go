@@ -0,0 +1,161 @@
+//go:build go1.23
+// +build go1.23
+
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/goplus/igop"
+	_ "github.com/goplus/igop/pkg/iter"
+)
+
+func TestRangeOverFunc(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"iter"
+)
+
+func Count(n int) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func Pairs(s []string) iter.Seq2[int, string] {
+	return func(yield func(int, string) bool) {
+		for i, v := range s {
+			if !yield(i, v) {
+				return
+			}
+		}
+	}
+}
+
+func find(s []string, x string) (int, bool) {
+	for i, v := range Pairs(s) {
+		if v == x {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+func First[T any](seq iter.Seq[T]) (T, bool) {
+	for v := range seq {
+		return v, true
+	}
+	var zero T
+	return zero, false
+}
+
+func main() {
+	var out []int
+	for i := range Count(10) {
+		if i%2 == 0 {
+			continue
+		}
+		if i > 7 {
+			break
+		}
+		out = append(out, i)
+	}
+	if fmt.Sprint(out) != "[1 3 5 7]" {
+		panic(fmt.Sprint(out))
+	}
+	out = nil
+outer:
+	for i := range Count(3) {
+		for j := range Count(3) {
+			if j == 2 {
+				continue outer
+			}
+			if i == 2 {
+				break outer
+			}
+			out = append(out, i*10+j)
+		}
+	}
+	if fmt.Sprint(out) != "[0 1 10 11]" {
+		panic(fmt.Sprint(out))
+	}
+	if i, ok := find([]string{"a", "b", "c"}, "c"); i != 2 || !ok {
+		panic(i)
+	}
+	if i, ok := find(nil, "c"); i != -1 || ok {
+		panic(i)
+	}
+	if v, ok := First(Count(3)); v != 0 || !ok {
+		panic(v)
+	}
+	var fns []func() int
+	for i := range Count(3) {
+		fns = append(fns, func() int { return i })
+	}
+	if fns[0]() != 0 || fns[2]() != 2 {
+		panic("per-iteration variable")
+	}
+	func() {
+		defer func() {
+			r := recover()
+			if s := fmt.Sprint(r); s != "runtime error: range function continued iteration after function for loop body returned false" {
+				panic(s)
+			}
+		}()
+		bad := func(yield func(int) bool) {
+			yield(1)
+			yield(2)
+		}
+		for range bad {
+			break
+		}
+	}()
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetGoVersion("go1.23")
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRangeOverFuncDefer(t *testing.T) {
+	src := `package main
+
+func main() {
+	for range func(yield func() bool) { yield() } {
+		defer println("defer")
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetGoVersion("go1.23")
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err == nil || !strings.Contains(err.Error(), "defer in range-over-func loop body is not supported") {
+		t.Fatalf("must report unsupported defer, got %v", err)
+	}
+}
@@ -16,33 +16,55 @@ package igop_test
 // fmt or testing, as it proved too fragile.
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"go/constant"
+	"go/types"
 	"io"
 	"io/fs"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 	"time"
 
 	"github.com/goplus/igop"
 	"github.com/goplus/igop/testdata/info"
+	"golang.org/x/tools/go/ssa"
 
 	_ "github.com/goplus/igop/pkg/bytes"
+	_ "github.com/goplus/igop/pkg/context"
+	_ "github.com/goplus/igop/pkg/encoding/gob"
+	_ "github.com/goplus/igop/pkg/encoding/json"
 	_ "github.com/goplus/igop/pkg/errors"
+	_ "github.com/goplus/igop/pkg/flag"
 	_ "github.com/goplus/igop/pkg/fmt"
+	_ "github.com/goplus/igop/pkg/html/template"
+	_ "github.com/goplus/igop/pkg/io"
+	_ "github.com/goplus/igop/pkg/io/fs"
+	_ "github.com/goplus/igop/pkg/io/ioutil"
 	_ "github.com/goplus/igop/pkg/math"
+	_ "github.com/goplus/igop/pkg/math/rand"
 	_ "github.com/goplus/igop/pkg/os"
+	_ "github.com/goplus/igop/pkg/os/signal"
 	_ "github.com/goplus/igop/pkg/path/filepath"
 	_ "github.com/goplus/igop/pkg/reflect"
 	_ "github.com/goplus/igop/pkg/runtime"
+	_ "github.com/goplus/igop/pkg/sort"
 	_ "github.com/goplus/igop/pkg/strings"
 	_ "github.com/goplus/igop/pkg/sync"
+	_ "github.com/goplus/igop/pkg/testing"
+	_ "github.com/goplus/igop/pkg/text/template"
 	_ "github.com/goplus/igop/pkg/time"
 )
 
@@ -2157,6 +2179,95 @@ func main() {
 	}
 }
 
+func TestGoroutinePanic(t *testing.T) {
+	src := `package main
+
+func main() {
+	ch := make(chan int)
+	go func() {
+		ch <- 1
+		ch <- 2
+	}()
+	go func() {
+		panic("goroutine panic")
+	}()
+	<-ch
+	select {}
+}
+`
+	n := runtime.NumGoroutine()
+	for _, rctx := range []context.Context{nil, context.Background()} {
+		ctx := igop.NewContext(0)
+		ctx.RunContext = rctx
+		code, err := ctx.RunFile("main.go", src, nil)
+		if err == nil {
+			t.Fatal("must panic")
+		}
+		if code != 2 {
+			t.Fatalf("exit code %v, must 2", code)
+		}
+		if err.Error() != "goroutine panic" {
+			t.Fatal(err)
+		}
+	}
+	// the blocked goroutines of the program are ended
+	for i := 0; runtime.NumGoroutine() > n; i++ {
+		if i == 100 {
+			t.Fatalf("goroutines %v, must %v", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGoroutineOsExit(t *testing.T) {
+	src := `package main
+
+import "os"
+
+func main() {
+	ch := make(chan int)
+	go func() {
+		os.Exit(3)
+	}()
+	<-ch
+}
+`
+	code, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("exit code %v, must 3", code)
+	}
+}
+
+func TestGoroutinePanicBlockedMain(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+func main() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		panic("goroutine panic")
+	}()
+	wg.Wait()
+}
+`
+	start := time.Now()
+	code, err := igop.RunFile("main.go", src, nil, 0)
+	if err == nil || err.Error() != "goroutine panic" {
+		t.Fatalf("must panic, got %v", err)
+	}
+	if code != 2 {
+		t.Fatalf("exit code %v, must 2", code)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("panic reported after %v", d)
+	}
+}
+
 func TestGlobalExtFunc(t *testing.T) {
 	src := `package main
 import "math"
@@ -2760,3 +2871,4579 @@ func main() {
 		t.Fatal(err)
 	}
 }
+
+func TestErrorWrap(t *testing.T) {
+	src := `package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+type MyError struct {
+	Code int
+}
+
+func (e *MyError) Error() string {
+	return fmt.Sprintf("code %v", e.Code)
+}
+
+type WrapError struct {
+	Msg string
+	Err error
+}
+
+func (e WrapError) Error() string {
+	return e.Msg + ": " + e.Err.Error()
+}
+
+func (e WrapError) Unwrap() error {
+	return e.Err
+}
+
+var ErrBase = errors.New("base")
+
+func main() {
+	base := &MyError{100}
+	err := fmt.Errorf("context: %w", base)
+	if s := err.Error(); s != "context: code 100" {
+		panic(s)
+	}
+	if errors.Unwrap(err) != base {
+		panic("error unwrap")
+	}
+	if !errors.Is(err, base) {
+		panic("error errors.Is")
+	}
+	var e *MyError
+	if !errors.As(err, &e) {
+		panic("error errors.As")
+	}
+	if e != base || e.Code != 100 {
+		panic("error errors.As value")
+	}
+	err = fmt.Errorf("outer: %w", WrapError{"inner", ErrBase})
+	if !errors.Is(err, ErrBase) {
+		panic("error errors.Is chain")
+	}
+	var we WrapError
+	if !errors.As(err, &we) || we.Msg != "inner" {
+		panic("error errors.As chain")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIntSize32(t *testing.T) {
+	src := `package main
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+func main() {
+	var x int = 2147483647
+	x++
+	if x != -2147483648 {
+		panic(x)
+	}
+	var u uint = 4294967295
+	u++
+	if u != 0 {
+		panic(u)
+	}
+	var n int64 = 1 << 32
+	if v := int(n + 1); v != 1 {
+		panic(v)
+	}
+	if s := unsafe.Sizeof(x); s != unsafe.Sizeof(uintptr(0)) {
+		panic(s)
+	}
+	if v := atoi("3000000000"); v != -1294967296 {
+		panic(v)
+	}
+	if v, _ := strconv.Atoi("3000000000"); v != -1294967296 {
+		panic(v)
+	}
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+`
+	ctx := igop.NewContext(0)
+	if err := ctx.SetIntSize(32); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// uintptr keeps the host size, it may hold an address
+	_, err = ctx.RunFile("main.go", `package main
+
+import "unsafe"
+
+type S struct {
+	A int64
+	B int32
+}
+
+func main() {
+	s := &S{A: 1, B: 2}
+	p := (*int32)(unsafe.Pointer(uintptr(unsafe.Pointer(s)) + unsafe.Offsetof(s.B)))
+	*p = 100
+	if s.B != 100 {
+		panic(s.B)
+	}
+}
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRangeInvalidUTF8(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func check(s string, want string) {
+	var list []string
+	for i, r := range s {
+		list = append(list, fmt.Sprintf("%v:%U", i, r))
+	}
+	if v := fmt.Sprint(list); v != want {
+		panic(fmt.Errorf("range %q: have %v, want %v", s, v, want))
+	}
+}
+
+func main() {
+	check("a\xffb", "[0:U+0061 1:U+FFFD 2:U+0062]")
+	check("\xe4\xb8", "[0:U+FFFD 1:U+FFFD]")
+	check("\xe4\xb8\x96x\x80", "[0:U+4E16 3:U+0078 4:U+FFFD]")
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInjectFault(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+)
+
+func main() {
+	for i := 1; i <= 3; i++ {
+		_, err := os.Getwd()
+		if i == 2 {
+			if err == nil || err.Error() != "inject fault" {
+				panic("must inject fault")
+			}
+		} else if err != nil {
+			panic(err)
+		}
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	var calls int
+	ctx.InjectFault("os.Getwd", func(call *igop.FaultCall) (time.Duration, error) {
+		calls++
+		if call.Count == 2 {
+			return 0, errors.New("inject fault")
+		}
+		return 0, nil
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("policy calls %v, want 3", calls)
+	}
+}
+
+func TestSetGOOS(t *testing.T) {
+	src := `package main
+
+import (
+	"github.com/goplus/igop/testdata/goos"
+)
+
+func main() {
+	if goos.Info != "%v" {
+		panic(goos.Info)
+	}
+}
+`
+	for _, v := range []struct {
+		goos string
+		info string
+	}{
+		{"linux", "linux"},
+		{"windows", "other"},
+	} {
+		ctx := igop.NewContext(0)
+		ctx.SetGOOS(v.goos)
+		_, err := ctx.RunFile("main.go", fmt.Sprintf(src, v.info), nil)
+		if err != nil {
+			t.Fatalf("GOOS=%v: %v", v.goos, err)
+		}
+	}
+}
+
+func TestInterpEval(t *testing.T) {
+	src := `package main
+
+var N = 10
+
+func Add(a, b int) int {
+	return a + b
+}
+
+func main() {
+}
+`
+	ctx := igop.NewContext(0)
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		expr string
+		want interface{}
+	}{
+		{"1+2*3", 7},
+		{`len("abc")`, 3},
+		{"N", 10},
+		{"Add(N, 1)", 11},
+	} {
+		r, err := interp.Eval(v.expr)
+		if err != nil {
+			t.Fatalf("eval %v: %v", v.expr, err)
+		}
+		if r != v.want {
+			t.Fatalf("eval %v: have %v, want %v", v.expr, r, v.want)
+		}
+	}
+	if _, err := interp.Eval("Add(1)"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestNilInterfaceMethodCall(t *testing.T) {
+	src := `package main
+
+type Stringer interface {
+	String() string
+}
+
+func check(fn func()) {
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			panic("must runtime error")
+		}
+		if s := err.Error(); s != "runtime error: invalid memory address or nil pointer dereference" {
+			panic(s)
+		}
+	}()
+	fn()
+}
+
+func main() {
+	var s Stringer
+	check(func() {
+		_ = s.String()
+	})
+	check(func() {
+		defer s.String()
+	})
+	check(func() {
+		go s.String()
+	})
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnablePrintAnyNested(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	x, y int
+}
+
+type T struct {
+	Name  string
+	Pos   [2]Point
+	Scale float64
+	ok    bool
+}
+
+func main() {
+	println(T{"abc", [2]Point{{1, 2}, {3, 4}}, 1.5, true})
+	println([2][2]int{{1, 2}, {3, 4}})
+}
+`
+	ctx := igop.NewContext(igop.EnablePrintAny)
+	var buf bytes.Buffer
+	ctx.SetPrintOutput(&buf)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "{abc [{1 2} {3 4}] +1.500000e+000 true}\n[[1 2] [3 4]]\n" {
+		t.Fatal(s)
+	}
+}
+
+func TestUnresolvedFuncHandler(t *testing.T) {
+	pkg := `package mypkg
+
+func Helper(a, b int) int
+`
+	src := `package main
+
+import "mypkg"
+
+func main() {
+	if v := mypkg.Helper(100, 200); v != 300 {
+		panic(v)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	err := ctx.AddImportFile("mypkg", "mypkg.go", pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resolved []string
+	ctx.SetUnresolvedFuncHandler(func(fullname string, sig *types.Signature) (reflect.Value, bool) {
+		resolved = append(resolved, fullname)
+		if fullname == "mypkg.Helper" && sig.Params().Len() == 2 {
+			return reflect.ValueOf(func(a, b int) int {
+				return a + b
+			}), true
+		}
+		return reflect.Value{}, false
+	})
+	// the resolved func is cached for the second run
+	for i := 0; i < 2; i++ {
+		_, err = ctx.RunFile("main.go", src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(resolved) != 1 || resolved[0] != "mypkg.Helper" {
+		t.Fatalf("bad resolved: %v", resolved)
+	}
+}
+
+func TestAppendAlias(t *testing.T) {
+	src := `package main
+
+func main() {
+	a := make([]int, 3, 10)
+	b := a[:1]
+	b = append(b, 100, 200)
+	if a[1] != 100 || a[2] != 200 {
+		panic("must alias")
+	}
+	b[0] = 1
+	if a[0] != 1 {
+		panic("must share")
+	}
+	c := append(a[:3], a[:3]...)
+	if len(c) != 6 || c[3] != 1 || c[4] != 100 || c[5] != 200 {
+		panic(c)
+	}
+	d := append(a[:10], 1)
+	d[0] = -1
+	if a[0] != 1 {
+		panic("must not alias")
+	}
+	s := append([]byte("hello")[:1:5], "ey"...)
+	if string(s) != "hey" {
+		panic(string(s))
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeferMethodReceiver(t *testing.T) {
+	src := `package main
+
+type Closer interface {
+	Close()
+}
+
+type file struct {
+	name string
+}
+
+var closed []string
+
+func (f *file) Close() {
+	closed = append(closed, f.name)
+}
+
+func (f file) Name() {
+	closed = append(closed, f.name)
+}
+
+func test() {
+	var c Closer = &file{"a"}
+	defer c.Close()
+	c = &file{"b"}
+	f := file{"c"}
+	defer f.Name()
+	f.name = "d"
+	m := c.Close
+	defer m()
+	c = nil
+}
+
+func main() {
+	test()
+	if len(closed) != 3 || closed[0] != "b" || closed[1] != "c" || closed[2] != "a" {
+		panic(closed)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCoverFunc(t *testing.T) {
+	src := `package main
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func main() {
+	abs(5)
+}
+`
+	ctx := igop.NewContext(0)
+	var fn *igop.Function
+	executed := make(map[int]bool)
+	ctx.SetCoverFunc(func(pfn *igop.Function, pc int) {
+		if pfn.Fn.Name() == "abs" {
+			fn = pfn
+			executed[pfn.PositionForPC(pc).Line] = true
+		}
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fn == nil {
+		t.Fatal("abs not executed")
+	}
+	if lines := fn.SourceLines(); !reflect.DeepEqual(lines, []int{4, 5, 7}) {
+		t.Fatalf("bad source lines: %v", lines)
+	}
+	delete(executed, 0)
+	if !reflect.DeepEqual(executed, map[int]bool{4: true, 7: true}) {
+		t.Fatalf("bad executed lines: %v", executed)
+	}
+}
+
+func TestMultiValueVariadic(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func g() (int, int) {
+	return 100, 200
+}
+
+func sum(a ...int) (n int) {
+	for _, v := range a {
+		n += v
+	}
+	return
+}
+
+func main() {
+	if n := sum(g()); n != 300 {
+		panic(n)
+	}
+	f := sum
+	if n := f(g()); n != 300 {
+		panic(n)
+	}
+	s := []int{1, 2, 3}
+	if n := sum(s...); n != 6 {
+		panic(n)
+	}
+	if s := fmt.Sprint(g()); s != "100 200" {
+		panic(s)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetFileSystem(t *testing.T) {
+	src := `package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("/data/hello.txt")
+	if err != nil {
+		panic(err)
+	}
+	if string(data) != "hello igop" {
+		panic(string(data))
+	}
+	if _, err := os.ReadFile("data/none.txt"); !errors.Is(err, os.ErrNotExist) {
+		panic(err)
+	}
+	if data, err := ioutil.ReadFile("data/hello.txt"); err != nil || string(data) != "hello igop" {
+		panic(err)
+	}
+	if infos, err := ioutil.ReadDir("data"); err != nil || len(infos) != 1 || infos[0].Name() != "hello.txt" {
+		panic(err)
+	}
+	if data, err := fs.ReadFile(os.DirFS("/data"), "hello.txt"); err != nil || string(data) != "hello igop" {
+		panic(err)
+	}
+	// fstest.MapFS does not open files as *os.File
+	if _, err := os.Open("data/hello.txt"); !errors.Is(err, os.ErrPermission) {
+		panic(err)
+	}
+	denied := []error{
+		os.WriteFile("data/hello.txt", nil, 0644),
+		ioutil.WriteFile("data/hello.txt", nil, 0644),
+		os.Truncate("data/hello.txt", 0),
+		os.Chown("data/hello.txt", 0, 0),
+		os.Chtimes("data/hello.txt", time.Now(), time.Now()),
+		os.Chdir("data"),
+		os.Symlink("data/hello.txt", "data/link.txt"),
+		os.Link("data/hello.txt", "data/link.txt"),
+	}
+	_, err = os.Readlink("data/link.txt")
+	denied = append(denied, err)
+	_, err = os.CreateTemp("", "igop")
+	denied = append(denied, err)
+	_, err = os.MkdirTemp("", "igop")
+	denied = append(denied, err)
+	_, err = ioutil.TempFile("", "igop")
+	denied = append(denied, err)
+	_, err = ioutil.TempDir("", "igop")
+	denied = append(denied, err)
+	for i, err := range denied {
+		if !errors.Is(err, os.ErrPermission) {
+			panic(fmt.Sprint(i, err))
+		}
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.RegisterExternal("os.ReadFile", func(name string) ([]byte, error) {
+		return []byte("hello " + name), nil
+	})
+	ctx.SetFileSystem(fstest.MapFS{
+		"data/hello.txt": &fstest.MapFile{Data: []byte("hello igop")},
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// restore the os.ReadFile registered before
+	ctx.SetFileSystem(nil)
+	_, err = ctx.RunFile("main.go", `package main
+
+import "os"
+
+func main() {
+	data, err := os.ReadFile("x")
+	if err != nil || string(data) != "hello x" {
+		panic(string(data))
+	}
+}
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypedNilInterface(t *testing.T) {
+	src := `package main
+
+type T struct{}
+
+func (*T) Error() string {
+	return "T"
+}
+
+func newT() *T {
+	return nil
+}
+
+func check(v interface{}) {
+	if v == nil {
+		panic("must not nil")
+	}
+}
+
+func main() {
+	var err error = (*T)(nil)
+	if err == nil {
+		panic("typed nil error must not nil")
+	}
+	err = newT()
+	if err == nil {
+		panic("typed nil error must not nil")
+	}
+	var fn func()
+	var m map[int]int
+	var s []int
+	var c chan int
+	var p *T
+	check(fn)
+	check(m)
+	check(s)
+	check(c)
+	check(p)
+	check(newT())
+	var e interface{}
+	if e != nil {
+		panic("must nil")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeAssertPointerPanic(t *testing.T) {
+	src := `package main
+
+import "runtime"
+
+func main() {
+	defer func() {
+		r := recover()
+		err, ok := r.(runtime.Error)
+		if !ok {
+			panic("must runtime.Error")
+		}
+		if s := err.Error(); s != "interface conversion: interface {} is *int, not *string" {
+			panic(s)
+		}
+	}()
+	var i interface{} = new(int)
+	_ = i.(*string)
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type runtimeGreeter struct {
+	Name string
+}
+
+func (g *runtimeGreeter) Hello() string {
+	return "hello " + g.Name
+}
+
+func TestRegisterRuntimePackage(t *testing.T) {
+	src := `package main
+
+import "host/greet"
+
+func main() {
+	g := greet.New("igop")
+	if s := g.Hello(); s != "hello igop" {
+		panic(s)
+	}
+	if s := greet.Add(100, 200); s != 300 {
+		panic(s)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err == nil {
+		t.Fatal("must not found package")
+	}
+	ctx = igop.NewContext(0)
+	err = ctx.RegisterRuntimePackage(&igop.Package{
+		Name: "greet",
+		Path: "host/greet",
+		NamedTypes: map[string]reflect.Type{
+			"Greeter": reflect.TypeOf((*runtimeGreeter)(nil)).Elem(),
+		},
+		Funcs: map[string]reflect.Value{
+			"New": reflect.ValueOf(func(name string) *runtimeGreeter {
+				return &runtimeGreeter{name}
+			}),
+			"Add": reflect.ValueOf(func(a, b int) int {
+				return a + b
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := igop.LookupPackage("host/greet"); ok {
+		t.Fatal("runtime package must not register globally")
+	}
+}
+
+func TestReflectInterfaceNamedType(t *testing.T) {
+	src := `package main
+
+import "reflect"
+
+type Point struct {
+	X, Y int
+}
+
+type Celsius float64
+
+func (c Celsius) String() string {
+	return "celsius"
+}
+
+func check(v interface{}) string {
+	switch v := reflect.ValueOf(v).Interface().(type) {
+	case Point:
+		return "Point"
+	case *Point:
+		return "*Point"
+	case Celsius:
+		return v.String()
+	case fmtStringer:
+		return "Stringer"
+	default:
+		return "unknown"
+	}
+}
+
+type fmtStringer interface {
+	String() string
+}
+
+func main() {
+	if s := check(Point{1, 2}); s != "Point" {
+		panic(s)
+	}
+	if s := check(&Point{1, 2}); s != "*Point" {
+		panic(s)
+	}
+	if s := check(Celsius(36.6)); s != "celsius" {
+		panic(s)
+	}
+	v := reflect.ValueOf([]Point{{1, 2}}).Index(0).Interface()
+	if p, ok := v.(Point); !ok || p.X != 1 || p.Y != 2 {
+		panic("bad Point")
+	}
+	e := reflect.New(reflect.TypeOf(Celsius(0))).Elem()
+	e.SetFloat(100)
+	if c, ok := e.Interface().(Celsius); !ok || c != 100 {
+		panic("bad Celsius")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllocStats(t *testing.T) {
+	src := `package main
+
+var list [][]int
+
+func main() {
+	for i := 0; i < 10; i++ {
+		list = append(list, make([]int, 8))
+	}
+	m := make(map[int]int)
+	m[1] = 1
+	ch := make(chan int64, 4)
+	ch <- 1
+	p := new([16]byte)
+	p[0] = 1
+	list = append(list, []int{int(<-ch), int(p[0])})
+}
+`
+	ctx := igop.NewContext(igop.EnableAllocStats)
+	pkg, err := ctx.LoadFile("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.NewInterp(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = interp.RunMain(); err != nil {
+		t.Fatal(err)
+	}
+	stats := interp.AllocStats()
+	if stats.Slices != 10 {
+		t.Fatalf("bad slices count: %v", stats.Slices)
+	}
+	if stats.Maps != 1 || stats.Chans != 1 {
+		t.Fatalf("bad maps/chans count: %v/%v", stats.Maps, stats.Chans)
+	}
+	if min := int64(10*8*strconv.IntSize/8 + 4*8); stats.Bytes < min {
+		t.Fatalf("bad bytes: %v < %v", stats.Bytes, min)
+	}
+}
+
+func TestShiftOverflow(t *testing.T) {
+	src := `package main
+
+func main() {
+	var x uint8 = 200
+	x <<= 2
+	if x != 32 {
+		panic(x)
+	}
+	var y int8 = 100
+	y <<= 1
+	if y != -56 {
+		panic(y)
+	}
+	var n uint = 7
+	if v := uint8(255) << n; v != 128 {
+		panic(v)
+	}
+	var z int32 = 1
+	if v := z << 40; v != 0 {
+		panic(v)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = `package main
+
+func main() {
+	var x int32 = 1 << 40
+	println(x)
+}
+`
+	_, err = igop.RunFile("main.go", src, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "overflows") {
+		t.Fatalf("must overflows error: %v", err)
+	}
+}
+
+func TestImportResolver(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "util.go"), []byte(`package util
+
+func Add(a, b int) int {
+	return a + b
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := `package main
+
+import "example.com/fake/util"
+
+func main() {
+	if v := util.Add(100, 200); v != 300 {
+		panic(v)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	var resolved []string
+	ctx.SetImportResolver(func(importPath string) (string, bool) {
+		resolved = append(resolved, importPath)
+		if importPath == "example.com/fake/util" {
+			return dir, true
+		}
+		return "", false
+	})
+	_, err = ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 1 || resolved[0] != "example.com/fake/util" {
+		t.Fatalf("bad resolved: %v", resolved)
+	}
+}
+
+func TestPointerMethodSet(t *testing.T) {
+	src := `package main
+
+type I interface {
+	Get() int
+	Set(v int)
+}
+
+type T struct {
+	v int
+}
+
+func (t T) Get() int {
+	return t.v
+}
+
+func (t *T) Set(v int) {
+	t.v = v
+}
+
+func main() {
+	var v interface{} = T{1}
+	if _, ok := v.(I); ok {
+		panic("T must not implement I")
+	}
+	func() {
+		defer func() {
+			r := recover()
+			if r == nil {
+				panic("must panic")
+			}
+			if s := r.(error).Error(); s != "interface conversion: main.T is not main.I: missing method Set" {
+				panic(s)
+			}
+		}()
+		_ = v.(I)
+	}()
+	var p interface{} = &T{1}
+	i, ok := p.(I)
+	if !ok {
+		panic("*T must implement I")
+	}
+	i.Set(100)
+	if i.Get() != 100 {
+		panic("bad Set")
+	}
+	if _, ok := v.(interface{ Get() int }); !ok {
+		panic("T must implement Get")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetOutputPipe(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	println("hello")
+	fmt.Println("world")
+	fmt.Fprintf(os.Stdout, "%v\n", 100)
+	fmt.Fprintln(os.Stderr, "error")
+}
+`
+	ctx := igop.NewContext(0)
+	pr, pw := io.Pipe()
+	if err := ctx.SetOutput(pw); err != nil {
+		t.Fatal(err)
+	}
+	var errbuf bytes.Buffer
+	if err := ctx.SetErrOutput(&errbuf); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := ctx.RunFile("main.go", src, nil)
+		ctx.SetOutput(nil)
+		ctx.SetErrOutput(nil)
+		pw.Close()
+		errc <- err
+	}()
+	var lines []string
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(lines, ","); s != "hello,world,100" {
+		t.Fatalf("bad output: %v", s)
+	}
+	if s := errbuf.String(); s != "error\n" {
+		t.Fatalf("bad err output: %q", s)
+	}
+}
+
+func TestSetOutputRuns(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func main() {
+	println("print")
+	fmt.Println("hello")
+}
+`
+	ctx := igop.NewContext(0)
+	var printed []string
+	ctx.RegisterExternal("fmt.Println", func(a ...interface{}) (int, error) {
+		printed = append(printed, fmt.Sprint(a...))
+		return 0, nil
+	})
+	var pbuf bytes.Buffer
+	ctx.SetPrintOutput(&pbuf)
+	var buf bytes.Buffer
+	if err := ctx.SetOutput(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// the output is flushed at the end of each run
+	for i := 1; i <= 2; i++ {
+		if _, err := ctx.RunFile("main.go", src, nil); err != nil {
+			t.Fatal(err)
+		}
+		if s := buf.String(); s != strings.Repeat("hello\n", i) {
+			t.Fatalf("bad output: %q", s)
+		}
+		if s := pbuf.String(); s != strings.Repeat("print\n", i) {
+			t.Fatalf("bad print output: %q", s)
+		}
+	}
+	// restore the fmt.Println registered before
+	ctx.SetOutput(nil)
+	if _, err := ctx.RunFile("main.go", src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(printed, ","); s != "hello" {
+		t.Fatalf("bad registered output: %v", s)
+	}
+}
+
+func TestSetOutputInterp(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+var out = os.Stdout
+
+func main() {
+	fmt.Println("main")
+}
+
+func Hello() {
+	fmt.Fprintln(out, "hello")
+}
+`
+	ctx := igop.NewContext(0)
+	var buf bytes.Buffer
+	if err := ctx.SetOutput(&buf); err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	// the output is flushed before RunMain and RunFunc return
+	if _, err := interp.RunMain(); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "main\n" {
+		t.Fatalf("bad output: %q", s)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := interp.RunFunc("Hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := buf.String(); s != "main\nhello\nhello\n" {
+		t.Fatalf("bad output: %q", s)
+	}
+}
+
+func TestDeferRecoverNamedResults(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+func div(a, b int) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New("recovered")
+		}
+	}()
+	n = 100
+	n = a / b
+	return
+}
+
+func fail() (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.New(r.(string))
+		}
+	}()
+	panic("fail")
+}
+
+func count() (n int, err error) {
+	defer func() {
+		n *= 2
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			n = 10
+			err = r.(error)
+		}
+	}()
+	panic(errors.New("count"))
+}
+
+func main() {
+	n, err := div(1, 0)
+	if n != 100 || err == nil || err.Error() != "recovered" {
+		panic("bad div")
+	}
+	n, err = div(6, 3)
+	if n != 2 || err != nil {
+		panic("bad div")
+	}
+	n, err = fail()
+	if n != 0 || err == nil || err.Error() != "fail" {
+		panic("bad fail")
+	}
+	n, err = count()
+	if n != 20 || err == nil || err.Error() != "count" {
+		panic("bad count")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsmFallback(t *testing.T) {
+	pkg := `package math
+
+func archHypot(p, q float64) float64
+
+func Hypot(p, q float64) float64 {
+	return archHypot(p, q)
+}
+`
+	src := `package main
+
+import "math"
+
+func main() {
+	if v := math.Hypot(3, 4); v != 5 {
+		panic(v)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	err := ctx.AddImportFile("math", "hypot.go", pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicOnUnexported(t *testing.T) {
+	src := `package main
+
+func add(a, b int) int {
+	return a + b
+}
+
+func Add(a, b int) int {
+	return add(a, b)
+}
+
+func main() {
+}
+`
+	ctx := igop.NewContext(0)
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := interp.GetFunc("add"); !ok {
+		t.Fatal("default must access unexported func")
+	}
+	interp.SetPanicOnUnexported(true)
+	if fn, ok := interp.GetFunc("Add"); !ok || fn.(func(int, int) int)(100, 200) != 300 {
+		t.Fatal("must access exported func")
+	}
+	if m, _, ok := interp.GetSymbol("add"); ok || m != nil {
+		t.Fatal("GetSymbol must not access unexported func")
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("GetFunc must panic on unexported func")
+		}
+	}()
+	interp.GetFunc("add")
+}
+
+func TestClosedChanCommaOk(t *testing.T) {
+	src := `package main
+
+func main() {
+	ch := make(chan int, 1)
+	ch <- 100
+	close(ch)
+	v, ok := <-ch
+	if v != 100 || !ok {
+		panic("must drain buffered value")
+	}
+	v, ok = <-ch
+	if v != 0 || ok {
+		panic("must zero and false")
+	}
+	s := make(chan string)
+	close(s)
+	if v, ok := <-s; v != "" || ok {
+		panic("must zero string and false")
+	}
+	select {
+	case v, ok := <-ch:
+		if v != 0 || ok {
+			panic("select must zero and false")
+		}
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanicErrorUnwrap(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"io"
+)
+
+type CodeError struct {
+	code int
+}
+
+func (e *CodeError) Error() string {
+	return fmt.Sprintf("code error %v", e.code)
+}
+
+func (e *CodeError) Code() int {
+	return e.code
+}
+
+func (e *CodeError) Unwrap() error {
+	return io.EOF
+}
+
+func main() {
+	panic(fmt.Errorf("wrap: %w", &CodeError{42}))
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err == nil {
+		t.Fatal("must panic")
+	}
+	if s := err.Error(); s != "wrap: code error 42" {
+		t.Fatal(s)
+	}
+	var coder interface {
+		Code() int
+	}
+	if !errors.As(err, &coder) || coder.Code() != 42 {
+		t.Fatal("errors.As must found CodeError")
+	}
+	if !errors.Is(err, io.EOF) {
+		t.Fatal("errors.Is must found io.EOF")
+	}
+	var perr igop.PanicError
+	if !errors.As(err, &perr) {
+		t.Fatal("must PanicError")
+	}
+}
+
+func TestLargeArrayCopy(t *testing.T) {
+	src := `package main
+
+type T struct {
+	name string
+	data [1000]int
+}
+
+func mutate(a [1000]int) [1000]int {
+	for i := range a {
+		a[i] = -1
+	}
+	return a
+}
+
+func mutateT(t T) {
+	t.data[999] = -1
+}
+
+func main() {
+	var a [1000]int
+	for i := range a {
+		a[i] = i
+	}
+	b := mutate(a)
+	if a[0] != 0 || a[999] != 999 || b[999] != -1 {
+		panic("array must copy on call")
+	}
+	c := a
+	c[1] = -1
+	if a[1] != 1 {
+		panic("array must copy on assign")
+	}
+	t := T{"t", a}
+	mutateT(t)
+	if t.data[999] != 999 {
+		panic("struct array field must copy")
+	}
+	p := &t.data
+	d := *p
+	d[0] = -1
+	if t.data[0] != 0 {
+		panic("array must copy on deref")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkLargeArrayCopy(b *testing.B) {
+	src := `package main
+
+func sum(a [1000]int) (n int) {
+	for _, v := range a {
+		n += v
+	}
+	return
+}
+
+func Bench(n int) {
+	var a [1000]int
+	for i := 0; i < n; i++ {
+		a[i%1000] = i
+		sum(a)
+	}
+}
+
+func main() {
+}
+`
+	ctx := igop.NewContext(0)
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	fn, ok := interp.GetFunc("Bench")
+	if !ok {
+		b.Fatal("not found Bench")
+	}
+	b.ResetTimer()
+	fn.(func(int))(b.N)
+}
+
+func TestSanityCheckFunctions(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type T struct {
+	v int
+}
+
+func (t *T) Add(n int) {
+	t.v += n
+}
+
+func main() {
+	defer func() {
+		if r := recover(); r == nil {
+			panic("must panic")
+		}
+	}()
+	m := map[string]*T{"a": {1}}
+	for k, v := range m {
+		v.Add(len(k))
+	}
+	add := m["a"].Add
+	add(10)
+	if s := fmt.Sprint(m["a"].v); s != "12" {
+		panic(s)
+	}
+	ch := make(chan int, 1)
+	select {
+	case ch <- 1:
+	default:
+	}
+	var p *T
+	p.Add(1)
+}
+`
+	for _, mode := range []ssa.BuilderMode{ssa.SanityCheckFunctions, ssa.SanityCheckFunctions | ssa.NaiveForm} {
+		ctx := igop.NewContext(0)
+		ctx.SetBuilderMode(mode)
+		if ctx.BuilderMode != mode {
+			t.Fatalf("bad builder mode %v", ctx.BuilderMode)
+		}
+		_, err := ctx.RunFile("main.go", src, nil)
+		if err != nil {
+			t.Fatal(mode, err)
+		}
+	}
+}
+
+func TestMapCompositeLiteral(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	X, Y int
+}
+
+type Line struct {
+	From, To Point
+}
+
+func main() {
+	m := map[string]Point{
+		"a": {1, 2},
+		"b": {X: 3},
+	}
+	if m["a"].X != 1 || m["a"].Y != 2 || m["b"].X != 3 || m["b"].Y != 0 {
+		panic("bad map literal")
+	}
+	p := m["a"]
+	p.X = 100
+	if m["a"].X != 1 {
+		panic("map value must copy")
+	}
+	m["a"] = p
+	if m["a"].X != 100 {
+		panic("bad map assign")
+	}
+	lines := map[Point]Line{
+		{0, 0}: {To: Point{1, 1}},
+		{1, 1}: {From: Point{1, 1}, To: Point{2, 2}},
+	}
+	if lines[Point{1, 1}].To.Y != 2 || lines[Point{0, 0}].To.X != 1 {
+		panic("bad nested map literal")
+	}
+	ptrs := map[int]*Point{1: {1, 1}}
+	ptrs[1].X = 10
+	if ptrs[1].X != 10 {
+		panic("bad pointer map literal")
+	}
+	arrs := map[int][2]Point{1: {{1, 1}, {2, 2}}}
+	if arrs[1][1].Y != 2 {
+		panic("bad array map literal")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetLinkValue(t *testing.T) {
+	src := `package main
+
+var Version = "dev"
+
+var BuildTime string
+
+var initVersion string
+
+func init() {
+	initVersion = Version
+}
+
+func main() {
+	if initVersion != "v1.2.3" {
+		panic(initVersion)
+	}
+	if Version != "v1.2.3" {
+		panic(Version)
+	}
+	if BuildTime != "2022-01-01" {
+		panic(BuildTime)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetLinkValue("main", "Version", "v1.2.3")
+	ctx.SetLinkValue("main", "BuildTime", "2022-01-01")
+	ctx.SetLinkValue("main", "NotFound", "skip")
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNestedRecover(t *testing.T) {
+	src := `package main
+
+func direct() (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	panic("direct")
+}
+
+func nested() (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	defer func() {
+		func() {
+			if v := recover(); v != nil {
+				panic("recover too deep must not work")
+			}
+		}()
+	}()
+	panic("nested")
+}
+
+func helper() interface{} {
+	return recover()
+}
+
+func indirect() (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	defer func() {
+		if v := helper(); v != nil {
+			panic("recover in helper must not work")
+		}
+	}()
+	panic("indirect")
+}
+
+func main() {
+	if r := direct(); r != "direct" {
+		panic(r)
+	}
+	if r := nested(); r != "nested" {
+		panic(r)
+	}
+	if r := indirect(); r != "indirect" {
+		panic(r)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHostPanicStack(t *testing.T) {
+	pkg := `package mypkg
+
+func Call(i, j int) int
+`
+	src := `package main
+
+import "mypkg"
+
+func main() {
+	println(mypkg.Call(10, 20))
+}
+`
+	ctx := igop.NewContext(igop.EnableHostPanicStack)
+	if err := ctx.AddImportFile("mypkg", "mypkg.go", pkg); err != nil {
+		t.Fatal(err)
+	}
+	// malformed external: signature does not match mypkg.Call
+	ctx.RegisterExternal("mypkg.Call", func(s string) string {
+		return s
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err == nil {
+		t.Fatal("must panic")
+	}
+	fe, ok := err.(igop.FatalError)
+	if !ok {
+		t.Fatalf("error type %T, want igop.FatalError", err)
+	}
+	if len(fe.HostStack()) == 0 {
+		t.Fatal("host stack not captured")
+	}
+	if !strings.Contains(err.Error(), "goroutine ") || !strings.Contains(err.Error(), "reflect.Value.call") {
+		t.Fatalf("error does not contain host stack: %v", err)
+	}
+}
+
+func TestInterfaceAssertWiden(t *testing.T) {
+	src := `package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+func main() {
+	var rw io.ReadWriter = bytes.NewBufferString("hello")
+	var x interface{} = rw
+	r, ok := x.(io.Reader)
+	if !ok {
+		panic("must be io.Reader")
+	}
+	if s := fmt.Sprintf("%T", r); s != "*bytes.Buffer" {
+		panic(s)
+	}
+	buf := make([]byte, 5)
+	if n, err := r.Read(buf); n != 5 || err != nil || string(buf) != "hello" {
+		panic(fmt.Errorf("read %v %v %q", n, err, buf))
+	}
+	if _, ok := r.(io.Writer); !ok {
+		panic("dynamic type lost")
+	}
+	_ = rw.(io.Reader)
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncMutex(t *testing.T) {
+	src := `package main
+
+import "sync"
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (c *Counter) Inc() {
+	c.mu.Lock()
+	c.n++
+	c.mu.Unlock()
+}
+
+type Global struct {
+	once sync.Once
+	sync.Mutex
+	v    int
+}
+
+var g Global
+
+func main() {
+	c := &Counter{}
+	mu := &sync.Mutex{}
+	m := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Inc()
+				mu.Lock()
+				m++
+				mu.Unlock()
+				g.Lock()
+				g.v++
+				g.Unlock()
+				g.once.Do(func() { g.v += 10000 })
+			}
+		}()
+	}
+	wg.Wait()
+	if c.n != 2000 {
+		panic(c.n)
+	}
+	if m != 2000 {
+		panic(m)
+	}
+	if g.v != 12000 {
+		panic(g.v)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTemplateFuncMap(t *testing.T) {
+	src := `package main
+
+import (
+	"bytes"
+	htemplate "html/template"
+	"strings"
+	"text/template"
+)
+
+type User struct {
+	Name string
+	Age  int
+}
+
+func (u *User) Greet(s string) string {
+	return s + ", " + u.Name
+}
+
+func upper(s string) string {
+	return strings.ToUpper(s)
+}
+
+func main() {
+	prefix := "#"
+	funcs := template.FuncMap{
+		"upper": upper,
+		"tag": func(n int) string {
+			return prefix + strings.Repeat("*", n)
+		},
+	}
+	tpl := template.Must(template.New("t").Funcs(funcs).Parse(
+		"{{upper .Name}} {{tag .Age}} {{.Greet \"hi\"}}"))
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, &User{"gop", 3}); err != nil {
+		panic(err)
+	}
+	if s := buf.String(); s != "GOP #*** hi, gop" {
+		panic(s)
+	}
+	htpl := htemplate.Must(htemplate.New("h").Funcs(htemplate.FuncMap{
+		"upper": upper,
+	}).Parse("<b>{{upper .Name}}</b>"))
+	buf.Reset()
+	if err := htpl.Execute(&buf, &User{Name: "<x>"}); err != nil {
+		panic(err)
+	}
+	if s := buf.String(); s != "<b>&lt;X&gt;</b>" {
+		panic(s)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamedSliceConvert(t *testing.T) {
+	src := `package main
+
+type MySlice []int
+
+func (s MySlice) Sum() (n int) {
+	for _, v := range s {
+		n += v
+	}
+	return
+}
+
+func main() {
+	a := []int{1, 2, 3}
+	m := MySlice(a)
+	m[0] = 10
+	if a[0] != 10 {
+		panic("MySlice(a) must share backing array")
+	}
+	b := []int(m)
+	b[1] = 20
+	if m[1] != 20 || a[1] != 20 {
+		panic("[]int(m) must share backing array")
+	}
+	if &a[0] != &m[0] || &b[0] != &m[0] {
+		panic("address mismatch")
+	}
+	if cap(m) != cap(a) || cap(b) != cap(a) {
+		panic("cap mismatch")
+	}
+	var m2 MySlice = a[:2]
+	if n := m2.Sum(); n != 30 {
+		panic(n)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAssertInterfaceHoldingInterface(t *testing.T) {
+	src := `package main
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+func main() {
+	var r io.Reader = bytes.NewReader([]byte("data"))
+	var x interface{} = r
+	if typ := reflect.TypeOf(x); typ != reflect.TypeOf((*bytes.Reader)(nil)) {
+		panic(typ.String())
+	}
+	br, ok := x.(*bytes.Reader)
+	if !ok {
+		panic("must be *bytes.Reader")
+	}
+	if br.Len() != 4 {
+		panic(br.Len())
+	}
+	if _, ok := x.(io.Reader); !ok {
+		panic("must be io.Reader")
+	}
+	if _, ok := x.(*bytes.Buffer); ok {
+		panic("must not be *bytes.Buffer")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetIntrinsic(t *testing.T) {
+	src := `package main
+
+func fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return fib(n-1) + fib(n-2)
+}
+
+type T struct {
+	n int
+}
+
+func (t *T) Twice() int {
+	return t.n * 2
+}
+
+func main() {
+	if n := fib(30); n != 832040 {
+		panic(n)
+	}
+	t := &T{21}
+	if n := t.Twice(); n != 42 {
+		panic(n)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	var calls, mcalls int
+	ctx.SetIntrinsic("main.fib", func(args []reflect.Value) []reflect.Value {
+		calls++
+		n := int(args[0].Int())
+		a, b := 0, 1
+		for i := 0; i < n; i++ {
+			a, b = b, a+b
+		}
+		return []reflect.Value{reflect.ValueOf(a)}
+	})
+	ctx.SetIntrinsic("(*main.T).Twice", func(args []reflect.Value) []reflect.Value {
+		mcalls++
+		n := args[0].Elem().Field(0).Int()
+		return []reflect.Value{reflect.ValueOf(int(n * 2))}
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Fatalf("fib intrinsic calls %v, want 1", calls)
+	}
+	if mcalls != 1 {
+		t.Fatalf("Twice intrinsic calls %v, want 1", mcalls)
+	}
+}
+
+func TestNestedBlankField(t *testing.T) {
+	src := `package main
+
+type Inner struct {
+	_ int
+	X int
+	_ string
+}
+
+type Key struct {
+	Inner
+	_ [2]int
+	N Inner
+}
+
+func main() {
+	a := Key{Inner{1, 2, "a"}, [2]int{1, 2}, Inner{3, 4, "b"}}
+	b := Key{Inner{5, 2, "c"}, [2]int{3, 4}, Inner{6, 4, "d"}}
+	if a != b {
+		panic("blank fields must be ignored in comparison")
+	}
+	m := make(map[Key]int)
+	m[a] = 1
+	m[b]++
+	if len(m) != 1 || m[a] != 2 {
+		panic(m)
+	}
+	var x, y interface{} = a.N, b.N
+	if x != y {
+		panic("interface comparison")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCgoHostPackage(t *testing.T) {
+	src := `package main
+
+/*
+int add(int a, int b) {
+	return a + b;
+}
+*/
+import "C"
+
+func main() {
+	if n := C.add(100, 200); n != 300 {
+		panic(n)
+	}
+	if n := int(C.add(C.int(1), 2)); n != 3 {
+		panic(n)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err == nil || !strings.Contains(err.Error(), "unresolved cgo reference C.add") {
+		t.Fatalf("must report unresolved cgo function, got %v", err)
+	}
+	ctx = igop.NewContext(0)
+	err = ctx.RegisterRuntimePackage(&igop.Package{
+		Name: "C",
+		Path: "C",
+		AliasTypes: map[string]reflect.Type{
+			"int": reflect.TypeOf(int32(0)),
+		},
+		Funcs: map[string]reflect.Value{
+			"add": reflect.ValueOf(func(a, b int32) int32 {
+				return a + b
+			}),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverAcrossHostCallback(t *testing.T) {
+	src := `package main
+
+import (
+	"errors"
+	"sort"
+)
+
+var errLess = errors.New("less")
+
+func sortPanic(s []int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	sort.Slice(s, func(i, j int) bool {
+		if s[i] == 3 || s[j] == 3 {
+			panic(errLess)
+		}
+		return s[i] < s[j]
+	})
+	return nil
+}
+
+func nested(s []int) (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+	func() {
+		sort.Slice(s, func(i, j int) bool {
+			panic("nested")
+		})
+	}()
+	return nil
+}
+
+func main() {
+	if err := sortPanic([]int{5, 3, 1}); err != errLess {
+		panic(err)
+	}
+	if r := nested([]int{2, 1}); r != "nested" {
+		panic(r)
+	}
+	s := []int{3, 1, 2}
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	if s[0] != 1 || s[2] != 3 {
+		panic(s)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNamedIntQuoNegative(t *testing.T) {
+	src := `package main
+
+type Int int
+type Int8 int8
+type Int64 int64
+
+func main() {
+	var a, b Int = -7, 2
+	if q, r := a/b, a%b; q != -3 || r != -1 {
+		panic(q)
+	}
+	var c, d Int8 = -7, 2
+	if q, r := c/d, c%d; q != -3 || r != -1 {
+		panic(q)
+	}
+	var e, f Int64 = 7, -2
+	if q, r := e/f, e%f; q != -3 || r != 1 {
+		panic(q)
+	}
+	var x interface{} = a / b
+	if x.(Int) != -3 {
+		panic(x)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReflectDeepEqual(t *testing.T) {
+	src := `package main
+
+import "reflect"
+
+type Point struct {
+	X, Y int
+}
+
+type Node struct {
+	Name  string
+	Attrs map[string][]int
+	Next  *Node
+	Pts   map[Point]*Point
+	priv  []string
+}
+
+type Other Node
+
+func main() {
+	a := &Node{
+		Name:  "a",
+		Attrs: map[string][]int{"x": {1, 2}, "y": nil},
+		Next:  &Node{Name: "b"},
+		Pts:   map[Point]*Point{{1, 2}: {3, 4}},
+		priv:  []string{"p"},
+	}
+	b := &Node{
+		Name:  "a",
+		Attrs: map[string][]int{"y": nil, "x": {1, 2}},
+		Next:  &Node{Name: "b"},
+		Pts:   map[Point]*Point{{1, 2}: {3, 4}},
+		priv:  []string{"p"},
+	}
+	if !reflect.DeepEqual(a, b) {
+		panic("must be equal")
+	}
+	b.Attrs["x"][1] = 3
+	if reflect.DeepEqual(a, b) {
+		panic("must not be equal: nested slice")
+	}
+	b.Attrs["x"][1] = 2
+	b.priv[0] = "q"
+	if reflect.DeepEqual(*a, *b) {
+		panic("must not be equal: unexported field")
+	}
+	b.priv[0] = "p"
+	if reflect.DeepEqual(*a, Other(*b)) {
+		panic("must not be equal: different named types")
+	}
+	var x, y interface{} = Point{1, 2}, Point{1, 2}
+	if !reflect.DeepEqual(x, y) {
+		panic("interface")
+	}
+	c := &Node{Name: "c"}
+	c.Next = c
+	d := &Node{Name: "c"}
+	d.Next = d
+	if !reflect.DeepEqual(c, d) {
+		panic("cyclic")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	src := `package main
+
+func main() {
+	n := 0
+	for {
+		n++
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetTimeout(100 * time.Millisecond)
+	start := time.Now()
+	code, err := ctx.RunFile("main.go", src, nil)
+	if err != igop.ErrTimeout {
+		t.Fatalf("error %v, want %v", err, igop.ErrTimeout)
+	}
+	if code != 2 {
+		t.Fatalf("exit code %v, want 2", code)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("timeout too slow: %v", d)
+	}
+	// the sleeping main is not interrupted, still a timeout
+	ctx = igop.NewContext(0)
+	ctx.SetTimeout(100 * time.Millisecond)
+	start = time.Now()
+	code, err = ctx.RunFile("main.go", `package main
+
+import "time"
+
+func main() {
+	time.Sleep(5 * time.Second)
+}
+`, nil)
+	if err != igop.ErrTimeout {
+		t.Fatalf("error %v, want %v", err, igop.ErrTimeout)
+	}
+	if code != 2 {
+		t.Fatalf("exit code %v, want 2", code)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("timeout too slow: %v", d)
+	}
+	ctx = igop.NewContext(0)
+	ctx.SetTimeout(10 * time.Second)
+	_, err = ctx.RunFile("main.go", "package main\n\nfunc main() {}\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStructConvertTags(t *testing.T) {
+	src := `package main
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+type A struct {
+	Name string ` + "`json:\"name\"`" + `
+	Age  int    ` + "`json:\"age\"`" + `
+}
+
+type B struct {
+	Name string ` + "`json:\"n\"`" + `
+	Age  int
+}
+
+func main() {
+	a := A{"gop", 10}
+	b := B(a)
+	b.Name = "igop"
+	if a.Name != "gop" {
+		panic("conversion must copy the value")
+	}
+	if b.Age != 10 {
+		panic(b.Age)
+	}
+	c := A(b)
+	if c.Name != "igop" || c.Age != 10 {
+		panic(c)
+	}
+	if tag := reflect.TypeOf(b).Field(0).Tag.Get("json"); tag != "n" {
+		panic(tag)
+	}
+	data, _ := json.Marshal(c)
+	if string(data) != ` + "`{\"name\":\"igop\",\"age\":10}`" + ` {
+		panic(string(data))
+	}
+	pa := &a
+	pb := (*B)(pa)
+	pb.Age = 20
+	if a.Age != 20 {
+		panic("pointer conversion must share the value")
+	}
+	var anon struct {
+		Name string
+		Age  int
+	} = struct {
+		Name string
+		Age  int
+	}(a)
+	if anon.Age != 20 {
+		panic(anon.Age)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetSizes(t *testing.T) {
+	src := `package main
+
+import "unsafe"
+
+type S struct {
+	a int8
+	b int64
+	c int32
+}
+
+func main() {
+	var s S
+	if n := unsafe.Sizeof(s); n != 16 {
+		panic(n)
+	}
+	if n := unsafe.Offsetof(s.c); n != 12 {
+		panic(n)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetSizes(&types.StdSizes{WordSize: 4, MaxAlign: 4})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInitPanic(t *testing.T) {
+	ctx := igop.NewContext(0)
+	err := ctx.AddImportFile("example.com/dep", "dep.go", `package dep
+
+import "errors"
+
+var ErrInit = errors.New("dep init failed")
+
+func init() {
+	panic(ErrInit)
+}
+
+func Value() int { return 1 }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := ctx.LoadFile("main.go", `package main
+
+import "example.com/dep"
+
+var v = dep.Value()
+
+func main() {
+	println(v)
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.NewInterp(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = interp.RunInit()
+	if err == nil {
+		t.Fatal("init must panic")
+	}
+	if !strings.Contains(err.Error(), "dep init failed") {
+		t.Fatalf("error %q must contain the panic value", err)
+	}
+	pe, ok := err.(igop.PanicError)
+	if !ok {
+		t.Fatalf("error type %T, want igop.PanicError", err)
+	}
+	if e, ok := pe.Value.(error); !ok || e.Error() != "dep init failed" {
+		t.Fatalf("panic value %v", pe.Value)
+	}
+	_, err = igop.RunFile("main.go", `package main
+
+func init() {
+	panic("main init")
+}
+
+func main() {
+}
+`, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "main init") {
+		t.Fatalf("run error %v", err)
+	}
+}
+
+func TestSliceThreeIndexAppend(t *testing.T) {
+	src := `package main
+
+func main() {
+	s := []int{0, 1, 2, 3, 4, 5}
+	s2 := s[2:4:4]
+	if len(s2) != 2 || cap(s2) != 2 {
+		panic(cap(s2))
+	}
+	s2 = append(s2, 100)
+	if s[4] != 4 {
+		panic("append must not overwrite the original backing array")
+	}
+	s2[0] = 200
+	if s[2] != 2 {
+		panic("append must allocate a new backing array")
+	}
+	s3 := s[1:3:5]
+	if cap(s3) != 4 {
+		panic(cap(s3))
+	}
+	s3 = append(s3, 300)
+	if s[3] != 300 {
+		panic("append within capacity must share the backing array")
+	}
+	a := [5]int{1, 2, 3, 4, 5}
+	p := &a
+	s4 := p[1:2:3]
+	s4 = append(s4, 10, 20)
+	if a[2] != 3 || a[3] != 4 {
+		panic(a)
+	}
+	str := s[:0:0]
+	str = append(str, 1)
+	if s[0] != 0 {
+		panic("zero cap")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunEntry(t *testing.T) {
+	src := `package app
+
+import "os"
+
+var started bool
+
+func init() {
+	started = true
+}
+
+func Start() {
+	if !started {
+		panic("init must run before entry")
+	}
+	println("start", len(os.Args))
+	os.Exit(3)
+}
+
+func Value() int {
+	return 1
+}
+`
+	ctx := igop.NewContext(0)
+	var buf bytes.Buffer
+	ctx.SetPrintOutput(&buf)
+	pkg, err := ctx.LoadFile("app.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := ctx.RunEntry(pkg, "Start", []string{"-v"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != 3 {
+		t.Fatalf("exit code %v, want 3", code)
+	}
+	if s := buf.String(); s != "start 2\n" {
+		t.Fatalf("output %q", s)
+	}
+	if _, err := ctx.RunEntry(pkg, "Value", nil); err == nil {
+		t.Fatal("entry with results must fail")
+	}
+	if _, err := ctx.RunEntry(pkg, "main", nil); err == nil {
+		t.Fatal("missing entry must fail")
+	}
+}
+
+func TestNarrowingConversion(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type I8 int8
+
+func conv(v int64, u uint64) string {
+	return fmt.Sprint(int8(v), uint8(v), int16(v), uint16(v), int32(v), uint32(v),
+		int8(u), uint8(u), int16(u), uint32(u), I8(v), int64(u), uint64(v))
+}
+
+func main() {
+	var s string
+	for _, v := range []int64{0, 1, -1, 127, 128, 255, 256, 300, -129, -300, 65535, 70000, 1<<31 + 5, -1 << 40, 1<<63 - 1, -1 << 63} {
+		s += conv(v, uint64(v)) + ";"
+	}
+	println(s)
+}
+`
+	type I8 int8
+	conv := func(v int64, u uint64) string {
+		return fmt.Sprint(int8(v), uint8(v), int16(v), uint16(v), int32(v), uint32(v),
+			int8(u), uint8(u), int16(u), uint32(u), I8(v), int64(u), uint64(v))
+	}
+	var want string
+	for _, v := range []int64{0, 1, -1, 127, 128, 255, 256, 300, -129, -300, 65535, 70000, 1<<31 + 5, -1 << 40, 1<<63 - 1, -1 << 63} {
+		want += conv(v, uint64(v)) + ";"
+	}
+	if !strings.Contains(want, "44") {
+		t.Fatal("int8(300) must be 44")
+	}
+	ctx := igop.NewContext(0)
+	var buf bytes.Buffer
+	ctx.SetPrintOutput(&buf)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != want {
+		t.Fatalf("got\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestPackageByPath(t *testing.T) {
+	ctx := igop.NewContext(0)
+	err := ctx.AddImportFile("example.com/helper", "helper.go", `package helper
+
+const Version = "1.0"
+
+var Count int
+
+type Point struct {
+	X, Y int
+}
+
+func Add(a, b int) int {
+	Count++
+	return a + b
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := ctx.LoadFile("main.go", `package main
+
+import "example.com/helper"
+
+func main() {
+	println(helper.Add(1, 2))
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.NewInterp(pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := interp.PackageByPath("fmt"); ok {
+		t.Fatal("fmt is not interpreted")
+	}
+	if _, ok := interp.PackageByPath("example.com/none"); ok {
+		t.Fatal("package must not found")
+	}
+	p, ok := interp.PackageByPath("example.com/helper")
+	if !ok {
+		t.Fatal("not found package example.com/helper")
+	}
+	fn, ok := p.GetFunc("Add")
+	if !ok {
+		t.Fatal("not found func Add")
+	}
+	if n := fn.(func(int, int) int)(100, 200); n != 300 {
+		t.Fatalf("Add %v, want 300", n)
+	}
+	v, ok := p.GetVarAddr("Count")
+	if !ok {
+		t.Fatal("not found var Count")
+	}
+	if n := *v.(*int); n != 1 {
+		t.Fatalf("Count %v, want 1", n)
+	}
+	c, ok := p.GetConst("Version")
+	if !ok || constant.StringVal(c) != "1.0" {
+		t.Fatalf("Version %v", c)
+	}
+	typ, ok := p.GetType("Point")
+	if !ok || typ.Kind() != reflect.Struct || typ.NumField() != 2 {
+		t.Fatalf("Point %v", typ)
+	}
+	if m, ok := interp.PackageByPath("main"); !ok || m.Path() != "main" {
+		t.Fatal("not found main package")
+	}
+}
+
+func TestSelectNilChan(t *testing.T) {
+	src := `package main
+
+func main() {
+	var nilc chan int
+	var nils chan string
+	ready := make(chan int, 1)
+	for i := 0; i < 100; i++ {
+		ready <- i
+		select {
+		case <-nilc:
+			panic("nil recv chan chosen")
+		case nils <- "hello":
+			panic("nil send chan chosen")
+		case v := <-ready:
+			if v != i {
+				panic("bad value")
+			}
+		}
+	}
+	select {
+	case <-nilc:
+		panic("nil recv chan chosen")
+	case nils <- "hello":
+		panic("nil send chan chosen")
+	default:
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetRandSeed(t *testing.T) {
+	src := `package main
+
+import "math/rand"
+
+func main() {
+	for i := 0; i < 5; i++ {
+		print(rand.Intn(100), " ")
+	}
+}
+`
+	r := rand.New(rand.NewSource(42))
+	var want string
+	for i := 0; i < 5; i++ {
+		want += fmt.Sprintf("%v ", r.Intn(100))
+	}
+	for i := 0; i < 2; i++ {
+		var buf bytes.Buffer
+		ctx := igop.NewContext(0)
+		ctx.SetRandSeed(42)
+		ctx.SetPrintOutput(&buf)
+		_, err := ctx.RunFile("main.go", src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Fatalf("rand sequence %q, want %q", buf.String(), want)
+		}
+		// run again on the same context
+		buf.Reset()
+		_, err = ctx.RunFile("main.go", src, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Fatalf("rand sequence of second run %q, want %q", buf.String(), want)
+		}
+	}
+	// restore the override registered before
+	var buf bytes.Buffer
+	ctx := igop.NewContext(0)
+	ctx.RegisterExternal("math/rand.Intn", func(n int) int {
+		return 7
+	})
+	ctx.SetRandSeed(42)
+	ctx.SetRandSeed(42)
+	ctx.SetPrintOutput(&buf)
+	ctx.ClearRandSeed()
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "7 7 7 7 7 " {
+		t.Fatalf("rand sequence %q, want registered override", buf.String())
+	}
+}
+
+func TestMethodValueReceiver(t *testing.T) {
+	src := `package main
+
+type T struct {
+	n int
+}
+
+func (t T) Get() int {
+	return t.n
+}
+
+func (t *T) PtrGet() int {
+	return t.n
+}
+
+type Getter interface {
+	Get() int
+}
+
+func main() {
+	t := T{1}
+	get := t.Get
+	pget := t.PtrGet
+	t.n = 2
+	if v := get(); v != 1 {
+		panic(v)
+	}
+	if v := pget(); v != 2 {
+		panic(v)
+	}
+	p := &T{3}
+	get = p.Get
+	p.n = 4
+	if v := get(); v != 3 {
+		panic(v)
+	}
+	var g Getter = t
+	iget := g.Get
+	g = T{5}
+	if v := iget(); v != 2 {
+		panic(v)
+	}
+	fns := make([]func() int, 3)
+	for i := range fns {
+		t.n = i
+		fns[i] = t.Get
+	}
+	for i, fn := range fns {
+		if v := fn(); v != i {
+			panic(v)
+		}
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONStructTags(t *testing.T) {
+	src := `package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type Point struct {
+	X int ` + "`json:\"x\"`" + `
+	Y int ` + "`json:\"y,omitempty\"`" + `
+}
+
+type Shape struct {
+	Name   string  ` + "`json:\"name\"`" + `
+	Points []Point ` + "`json:\"points\"`" + `
+	Hidden string  ` + "`json:\"-\"`" + `
+	inner  int
+}
+
+func main() {
+	s := Shape{Name: "line", Points: []Point{{1, 2}, {3, 0}}, Hidden: "hidden", inner: 1}
+	data, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	want := ` + "`" + `{"name":"line","points":[{"x":1,"y":2},{"x":3}]}` + "`" + `
+	if string(data) != want {
+		panic(fmt.Errorf("marshal %s, want %s", data, want))
+	}
+	var v Shape
+	if err := json.Unmarshal([]byte(` + "`" + `{"name":"rect","points":[{"x":5,"y":6}],"Hidden":"x"}` + "`" + `), &v); err != nil {
+		panic(err)
+	}
+	if v.Name != "rect" || len(v.Points) != 1 || v.Points[0] != (Point{5, 6}) || v.Hidden != "" {
+		panic(fmt.Errorf("unmarshal %+v", v))
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestChanRecvCommaOk(t *testing.T) {
+	src := `package main
+
+type T struct {
+	s string
+	n int
+}
+
+func main() {
+	ch := make(chan T, 1)
+	ch <- T{"hello", 1}
+	v, ok := <-ch
+	if !ok || v != (T{"hello", 1}) {
+		panic("recv open chan")
+	}
+	close(ch)
+	v, ok = <-ch
+	if ok || v != (T{}) {
+		panic("recv closed chan")
+	}
+	c := make(chan int, 1)
+	c <- 100
+	var n int
+	n, ok = <-c
+	if !ok || n != 100 {
+		panic("recv int")
+	}
+	close(c)
+	if n, ok := <-c; ok || n != 0 {
+		panic("recv closed int")
+	}
+	p := make(chan *T)
+	close(p)
+	if v, ok := <-p; ok || v != nil {
+		panic("recv closed pointer")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetPackageWhitelist(t *testing.T) {
+	ctx := igop.NewContext(0)
+	ctx.SetPackageWhitelist([]string{"fmt", "strings"})
+	_, err := ctx.LoadFile("main.go", `package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func main() {
+	fmt.Println(exec.Command("ls"))
+}
+`)
+	if err == nil || !strings.Contains(err.Error(), `import "os/exec": package not allowed`) {
+		t.Fatalf("import os/exec must not allowed: %v", err)
+	}
+	ctx = igop.NewContext(0)
+	ctx.SetPackageWhitelist([]string{"fmt", "strings"})
+	_, err = ctx.RunFile("main.go", `package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func main() {
+	fmt.Println(strings.ToUpper("hello"))
+}
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeferInLoop(t *testing.T) {
+	src := `package main
+
+func main() {
+	for i := 0; i < 5; i++ {
+		defer print(i, " ")
+	}
+	for i := 5; i < 8; i++ {
+		defer func(n int) {
+			print(n, " ")
+		}(i)
+	}
+}
+`
+	var buf bytes.Buffer
+	ctx := igop.NewContext(0)
+	ctx.SetPrintOutput(&buf)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "7 6 5 4 3 2 1 0 " {
+		t.Fatalf("defer order %q", s)
+	}
+}
+
+func TestNamedResultsByReflect(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+type Point struct {
+	X, Y int
+}
+
+func Divide(a, b int) (q, r int, pt *Point, s string, err error) {
+	if b == 0 {
+		err = errors.New("divide by zero")
+		return
+	}
+	q, r = a/b, a%b
+	pt = &Point{q, r}
+	s = "ok"
+	return
+}
+
+func main() {
+}
+`
+	ctx := igop.NewContext(0)
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn, ok := interp.GetFunc("Divide")
+	if !ok {
+		t.Fatal("not found func Divide")
+	}
+	v := reflect.ValueOf(fn)
+	rs := v.Call([]reflect.Value{reflect.ValueOf(7), reflect.ValueOf(2)})
+	if len(rs) != 5 {
+		t.Fatalf("results %v", len(rs))
+	}
+	for i := 0; i < 5; i++ {
+		if rs[i].Type() != v.Type().Out(i) {
+			t.Fatalf("result %v type %v, want %v", i, rs[i].Type(), v.Type().Out(i))
+		}
+	}
+	if q, r := rs[0].Int(), rs[1].Int(); q != 3 || r != 1 {
+		t.Fatalf("Divide(7, 2) = %v, %v", q, r)
+	}
+	if pt := rs[2].Elem(); pt.Field(0).Int() != 3 || pt.Field(1).Int() != 1 {
+		t.Fatalf("pt %v", pt)
+	}
+	if rs[3].String() != "ok" || !rs[4].IsNil() {
+		t.Fatalf("s %v, err %v", rs[3], rs[4])
+	}
+	rs = v.Call([]reflect.Value{reflect.ValueOf(7), reflect.ValueOf(0)})
+	if rs[0].Int() != 0 || rs[1].Int() != 0 || !rs[2].IsNil() || rs[3].String() != "" {
+		t.Fatalf("early return must zero results: %v", rs)
+	}
+	if err, ok := rs[4].Interface().(error); !ok || err.Error() != "divide by zero" {
+		t.Fatalf("err %v", rs[4])
+	}
+}
+
+func TestUnsafePointerField(t *testing.T) {
+	src := `package main
+
+import "unsafe"
+
+type Node struct {
+	name string
+	ptr  unsafe.Pointer
+	next *Node
+}
+
+type Pair struct {
+	a, b int
+}
+
+func main() {
+	n := 100
+	var node Node
+	node.ptr = unsafe.Pointer(&n)
+	*(*int)(node.ptr) = 200
+	if n != 200 {
+		panic("bad ptr")
+	}
+	p := &Node{ptr: unsafe.Pointer(&Pair{1, 2})}
+	q := (*Pair)(p.ptr)
+	if q.a != 1 || q.b != 2 {
+		panic("bad pair")
+	}
+	nodes := [2]Node{{ptr: node.ptr}}
+	nodes[1] = nodes[0]
+	if *(*int)(nodes[1].ptr) != 200 || nodes[1].ptr != unsafe.Pointer(&n) {
+		panic("bad copy")
+	}
+	u := uintptr(p.ptr)
+	if unsafe.Pointer(u) != p.ptr {
+		panic("bad uintptr")
+	}
+	s := struct{ p unsafe.Pointer }{}
+	if s.p != nil {
+		panic("must nil")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetChannelHook(t *testing.T) {
+	src := `package main
+
+func main() {
+	ch := make(chan int)
+	done := make(chan bool)
+	go func() {
+		for v := range ch {
+			println(v)
+		}
+		done <- true
+	}()
+	for i := 1; i <= 3; i++ {
+		ch <- i
+	}
+	select {
+	case ch <- 4:
+	}
+	close(ch)
+	<-done
+}
+`
+	var mu sync.Mutex
+	var sends, recvs []interface{}
+	ctx := igop.NewContext(0)
+	ctx.SetChannelHook(func(op string, ch interface{}, val interface{}) {
+		if _, ok := ch.(chan int); !ok {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch op {
+		case "send":
+			sends = append(sends, val)
+		case "recv":
+			recvs = append(recvs, val)
+		}
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{1, 2, 3, 4}; !reflect.DeepEqual(sends, want) {
+		t.Fatalf("sends %v, want %v", sends, want)
+	}
+	// range over closed channel receives the zero value
+	if want := []interface{}{1, 2, 3, 4, 0}; !reflect.DeepEqual(recvs, want) {
+		t.Fatalf("recvs %v, want %v", recvs, want)
+	}
+}
+
+func TestStructCompare(t *testing.T) {
+	src := `package main
+
+type P struct {
+	X, Y int
+}
+
+type S struct {
+	name string
+	a    [2]P
+	b    [2][2]string
+	i    interface{}
+}
+
+type M struct {
+	n int
+	m map[string]int
+}
+
+func compare(x, y interface{}) (r bool, err interface{}) {
+	defer func() {
+		err = recover()
+	}()
+	return x == y, nil
+}
+
+func main() {
+	s1 := S{"s", [2]P{{1, 2}, {3, 4}}, [2][2]string{{"a"}, {"b", "c"}}, [1]int{1}}
+	s2 := S{"s", [2]P{{1, 2}, {3, 4}}, [2][2]string{{"a"}, {"b", "c"}}, [1]int{1}}
+	if s1 != s2 {
+		panic("nested arrays must equal")
+	}
+	if r, err := compare(s1, s2); !r || err != nil {
+		panic("nested arrays must equal as interface")
+	}
+	s2.a[1].Y = 5
+	if s1 == s2 {
+		panic("nested arrays must not equal")
+	}
+	_, err := compare(M{m: map[string]int{}}, M{m: map[string]int{}})
+	if e, ok := err.(error); !ok || e.Error() != "runtime error: comparing uncomparable type main.M" {
+		panic(err)
+	}
+	_, err = compare([1]M{}, [1]M{})
+	if e, ok := err.(error); !ok || e.Error() != "runtime error: comparing uncomparable type [1]main.M" {
+		panic(err)
+	}
+	_, err = compare(S{i: []int{}}, S{i: []int{}})
+	if e, ok := err.(error); !ok || e.Error() != "runtime error: comparing uncomparable type []int" {
+		panic(err)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInterfaceNilCompare(t *testing.T) {
+	src := `package main
+
+type Stringer interface {
+	String() string
+}
+
+type Named interface {
+	Stringer
+	Name() string
+}
+
+type T struct{}
+
+func (*T) String() string { return "T" }
+func (*T) Name() string   { return "T" }
+
+func isNil(v interface{}) bool {
+	return v == nil
+}
+
+func main() {
+	var p *T
+	var n Named = p
+	var s Stringer = n
+	var e interface{} = s
+	if r := n == nil; r != false {
+		panic("typed nil Named must not nil")
+	}
+	if r := s == nil; r != false {
+		panic("typed nil Stringer must not nil")
+	}
+	if r := e == nil; r != false {
+		panic("typed nil interface{} must not nil")
+	}
+	if r := isNil(s); r != false {
+		panic("typed nil arg must not nil")
+	}
+	if r := e.(*T) == nil; r != true {
+		panic("underlying pointer must nil")
+	}
+	var n2 Named
+	var s2 Stringer = n2
+	var e2 interface{} = s2
+	if r := n2 == nil; r != true {
+		panic("nil Named must nil")
+	}
+	if r := s2 == nil; r != true {
+		panic("nil Stringer must nil")
+	}
+	if r := e2 == nil; r != true {
+		panic("nil interface{} must nil")
+	}
+	if r := isNil(s2); r != true {
+		panic("nil arg must nil")
+	}
+	if r := nil == e; r != false {
+		panic("nil == typed nil must false")
+	}
+	if r := nil != e2; r != false {
+		panic("nil != nil must false")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	src := `package main
+
+import (
+	"context"
+	"time"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	for i := 0; i < 3; i++ {
+		go func() {
+			select {
+			case <-ctx.Done():
+				done <- ctx.Err()
+			case <-time.After(10 * time.Second):
+				done <- nil
+			}
+		}()
+	}
+	cancel()
+	for i := 0; i < 3; i++ {
+		if err := <-done; err != context.Canceled {
+			panic(err)
+		}
+	}
+	tctx, tcancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer tcancel()
+	<-tctx.Done()
+	if tctx.Err() != context.DeadlineExceeded {
+		panic(tctx.Err())
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLargeConstNarrowing(t *testing.T) {
+	src := `package main
+
+const (
+	Big   = 1 << 100
+	Huge  = Big * Big
+	Max64 = 1<<64 - 1
+)
+
+func main() {
+	var a uint64 = Big>>40 - 1
+	if a != 1<<60-1 || a != 0xfffffffffffffff {
+		panic(a)
+	}
+	var b uint64 = Huge >> 137
+	if b != 1<<63 || b != 9223372036854775808 {
+		panic(b)
+	}
+	var c uint64 = Max64
+	if c != 18446744073709551615 || c+1 != 0 {
+		panic(c)
+	}
+	var d uint64 = (Big + 12345) % (1 << 64)
+	if d != 12345 {
+		panic(d)
+	}
+	var e int64 = -(Big >> 37)
+	if e != -1<<63 || e != -9223372036854775808 {
+		panic(e)
+	}
+	const f = Huge / (Big - 1) // exact untyped integer division
+	var g uint64 = f - Big
+	if g != 1 {
+		panic(g)
+	}
+	var h float64 = Huge
+	if h != 1.6069380442589903e+60 {
+		panic(h)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResetForReuse(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+var (
+	Count int
+	Names []string
+	cache = map[string]int{"init": 1}
+	Inits int
+)
+
+func init() {
+	Inits++
+	fmt.Println("init")
+}
+
+func Hello() {
+	fmt.Println("hello", Inits)
+}
+
+func main() {
+	fmt.Println("main")
+	Count++
+	Names = append(Names, "main")
+	cache["main"]++
+	if Count != 1 || len(Names) != 1 || len(cache) != 2 || cache["main"] != 1 || Inits != 1 {
+		panic("globals must fresh")
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	var buf bytes.Buffer
+	if err := ctx.SetOutput(&buf); err != nil {
+		t.Fatal(err)
+	}
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if i > 0 {
+			if err := interp.ResetForReuse(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if code, err := interp.RunMain(); err != nil || code != 0 {
+			t.Fatalf("run %v: exit %v, %v", i, code, err)
+		}
+		v, ok := interp.GetVarAddr("Count")
+		if !ok || *v.(*int) != 1 {
+			t.Fatalf("run %v: Count %v", i, v)
+		}
+	}
+	if err := interp.ResetForReuse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interp.RunFunc("Hello"); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != strings.Repeat("init\nmain\n", 3)+"init\nhello 1\n" {
+		t.Fatalf("bad output: %q", s)
+	}
+}
+
+func TestAppendNoElements(t *testing.T) {
+	src := `package main
+
+func main() {
+	s := make([]int, 2, 10)
+	s[0], s[1] = 1, 2
+	t := append(s)
+	if len(t) != 2 || cap(t) != 10 || &t[0] != &s[0] {
+		panic("append(s) must return s")
+	}
+	var args []int
+	t = append(s, args...)
+	if len(t) != 2 || cap(t) != 10 || &t[0] != &s[0] {
+		panic("append(s, nil...) must return s")
+	}
+	t = append(s, nil...)
+	if len(t) != 2 || cap(t) != 10 || &t[0] != &s[0] {
+		panic("append(s, nil...) must return s")
+	}
+	t = append(s, []int{}...)
+	if len(t) != 2 || cap(t) != 10 || &t[0] != &s[0] {
+		panic("append(s, empty...) must return s")
+	}
+	var n []int
+	if r := append(n); r != nil {
+		panic("append(nil) must nil")
+	}
+	if r := append(n, nil...); r != nil {
+		panic("append(nil, nil...) must nil")
+	}
+	b := []byte("hi")
+	if r := append(b, ""...); string(r) != "hi" || &r[0] != &b[0] {
+		panic("append(b, empty string...) must return b")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnsafePointerArith(t *testing.T) {
+	src := `package main
+
+import "unsafe"
+
+type T struct {
+	a int8
+	b int64
+	c string
+	d [4]int32
+}
+
+func main() {
+	s := T{a: 1, b: 2, c: "hello", d: [4]int32{1, 2, 3, 4}}
+	pb := (*int64)(unsafe.Pointer(uintptr(unsafe.Pointer(&s)) + unsafe.Offsetof(s.b)))
+	if *pb != 2 {
+		panic(*pb)
+	}
+	*pb = 200
+	if s.b != 200 {
+		panic(s.b)
+	}
+	pc := (*string)(unsafe.Pointer(uintptr(unsafe.Pointer(&s)) + unsafe.Offsetof(s.c)))
+	*pc += " world"
+	if s.c != "hello world" {
+		panic(s.c)
+	}
+	base := uintptr(unsafe.Pointer(&s.d))
+	for i := 0; i < 4; i++ {
+		p := (*int32)(unsafe.Pointer(base + uintptr(i)*unsafe.Sizeof(s.d[0])))
+		*p *= 10
+	}
+	if s.d != [4]int32{10, 20, 30, 40} {
+		panic("bad array")
+	}
+	ps := &s
+	pa := (*int8)(unsafe.Pointer(uintptr(unsafe.Pointer(pb)) - unsafe.Offsetof(ps.b)))
+	if pa != &s.a || *pa != 1 {
+		panic("bad back offset")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func main() {
+	x := 1
+	x = x
+	fmt.Printf("%d\n", "hello")
+}
+`
+	ctx := igop.NewContext(igop.EnableDiagnostics)
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := ctx.Diagnostics()
+	if len(diags) != 2 {
+		t.Fatalf("diagnostics %v", diags)
+	}
+	if d := diags[0]; d.Category != "assign" || d.Pos.Line != 7 || d.Message != "self-assignment of x to x" {
+		t.Fatalf("bad diagnostic %v", d)
+	}
+	if d := diags[1]; d.Category != "printf" || d.Pos.Line != 8 || !strings.Contains(d.Message, "wrong type string") {
+		t.Fatalf("bad diagnostic %v", d)
+	}
+	ctx = igop.NewContext(0)
+	if _, err := ctx.RunFile("main.go", src, nil); err != nil {
+		t.Fatal(err)
+	}
+	if diags := ctx.Diagnostics(); len(diags) != 0 {
+		t.Fatalf("diagnostics must disabled: %v", diags)
+	}
+}
+
+func TestMethodPromotionMultiLevel(t *testing.T) {
+	src := `package main
+
+type C struct {
+	n int
+}
+
+func (c C) M() int {
+	return c.n
+}
+
+func (c *C) PM() int {
+	c.n++
+	return c.n
+}
+
+type B struct {
+	C
+}
+
+type A struct {
+	B
+}
+
+type PA struct {
+	*B
+}
+
+type Mer interface {
+	M() int
+}
+
+type PMer interface {
+	M() int
+	PM() int
+}
+
+func main() {
+	a := A{B{C{1}}}
+	if a.M() != 1 {
+		panic("direct call")
+	}
+	var i Mer = a
+	if i.M() != 1 {
+		panic("interface call")
+	}
+	var pi PMer = &a
+	if pi.PM() != 2 || pi.M() != 2 || a.n != 2 {
+		panic("pointer interface call")
+	}
+	f := a.M
+	g := A.M
+	h := (*A).PM
+	if f() != 2 || g(a) != 2 || h(&a) != 3 {
+		panic("method value and expression")
+	}
+	p := PA{&B{C{10}}}
+	var pj PMer = p
+	if pj.PM() != 11 || p.M() != 11 {
+		panic("embedded pointer")
+	}
+	if _, ok := interface{}(a).(PMer); ok {
+		panic("value A must not implement PMer")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncMap(t *testing.T) {
+	src := `package main
+
+import (
+	"sync"
+)
+
+type Cache struct {
+	name string
+	m    sync.Map
+}
+
+type Item struct {
+	id   int
+	name string
+}
+
+func main() {
+	c := &Cache{name: "cache"}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.m.Store(i, Item{i, "item"})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 100; i < 200; i++ {
+			c.m.Store(i, &Item{i, "pitem"})
+		}
+	}()
+	wg.Wait()
+	var n int
+	c.m.Range(func(k, v interface{}) bool {
+		n++
+		return true
+	})
+	if n != 200 {
+		panic(n)
+	}
+	v, ok := c.m.Load(10)
+	if !ok || v.(Item) != (Item{10, "item"}) {
+		panic("load 10")
+	}
+	v, ok = c.m.Load(150)
+	if !ok || *v.(*Item) != (Item{150, "pitem"}) {
+		panic("load 150")
+	}
+	if v, loaded := c.m.LoadOrStore(10, Item{}); !loaded || v.(Item).id != 10 {
+		panic("LoadOrStore")
+	}
+	c.m.Delete(10)
+	if _, ok := c.m.Load(10); ok {
+		panic("delete")
+	}
+	pm := &c.m
+	pm.Store("key", "value")
+	if v, ok := c.m.Load("key"); !ok || v != "value" {
+		panic("identity")
+	}
+	var m sync.Map
+	done := make(chan bool)
+	go func() {
+		m.Store(Item{1, "key"}, 100)
+		done <- true
+	}()
+	<-done
+	if v, ok := m.Load(Item{1, "key"}); !ok || v != 100 {
+		panic("struct key")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAppendNamedSliceSpread(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Buf []byte
+
+type Bytes []byte
+
+type Ints []int
+
+func main() {
+	var buf Buf
+	b := []byte("hello")
+	buf = append(buf, b...)
+	buf = append(buf, Bytes(" world")...)
+	if s := fmt.Sprintf("%T %s", buf, buf); s != "main.Buf hello world" {
+		panic(s)
+	}
+	out := append([]byte("say "), buf...)
+	if s := fmt.Sprintf("%T %s", out, out); s != "[]uint8 say hello world" {
+		panic(s)
+	}
+	small := make(Buf, 0, 100)
+	small = append(small, buf[:5]...)
+	if s := fmt.Sprintf("%T %s %v", small, small, cap(small)); s != "main.Buf hello 100" {
+		panic(s)
+	}
+	buf = append(buf, "!"...)
+	if s := fmt.Sprintf("%T %s", buf, buf); s != "main.Buf hello world!" {
+		panic(s)
+	}
+	ints := append(Ints{1}, []int{2, 3}...)
+	if s := fmt.Sprintf("%T %v", ints, ints); s != "main.Ints [1 2 3]" {
+		panic(s)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetPanicFilter(t *testing.T) {
+	src := `package main
+
+import "errors"
+
+var ErrDone = errors.New("done")
+
+func main() {
+	panic(ErrDone)
+}
+`
+	filter := func(v interface{}) (interface{}, bool) {
+		if err, ok := v.(error); ok && err.Error() == "done" {
+			return nil, true
+		}
+		if _, ok := v.(runtime.Error); ok {
+			return "filtered: " + v.(error).Error(), false
+		}
+		return v, false
+	}
+	ctx := igop.NewContext(0)
+	ctx.SetPanicFilter(filter)
+	code, err := ctx.RunFile("main.go", src, nil)
+	if err != nil || code != 0 {
+		t.Fatalf("sentinel panic must suppressed: %v %v", code, err)
+	}
+	ctx = igop.NewContext(0)
+	code, err = ctx.RunFile("main.go", src, nil)
+	if err == nil || err.Error() != "done" {
+		t.Fatalf("must panic done: %v %v", code, err)
+	}
+	ctx = igop.NewContext(0)
+	ctx.SetPanicFilter(filter)
+	interp, err := ctx.LoadInterp("main.go", strings.Replace(src, "panic(ErrDone)", "var m map[string]int\n\tm[ErrDone.Error()] = 1", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	_, err = interp.RunFunc("main")
+	if err == nil || !strings.HasPrefix(err.Error(), "filtered: assignment to entry in nil map") {
+		t.Fatalf("runtime error must transformed: %v", err)
+	}
+}
+
+func TestConstDivideByZero(t *testing.T) {
+	_, err := igop.RunFile("main.go", `package main
+
+const x = 1 / 0
+
+func main() {
+	println(x)
+}
+`, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("const division by zero must build error: %v", err)
+	}
+	_, err = igop.RunFile("main.go", `package main
+
+func main() {
+	var a int = 10
+	println(a % 0)
+}
+`, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("division by const zero must build error: %v", err)
+	}
+	src := `package main
+
+import "runtime"
+
+func div(a, b int) (r int, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = e.(runtime.Error)
+		}
+	}()
+	return a / b, nil
+}
+
+func main() {
+	if r, err := div(10, 2); r != 5 || err != nil {
+		panic("div(10, 2)")
+	}
+	_, err := div(1, 0)
+	if err == nil || err.Error() != "runtime error: integer divide by zero" {
+		panic(err)
+	}
+}
+`
+	_, err = igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGobInterpretedStruct(t *testing.T) {
+	src := `package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+)
+
+type Point struct {
+	X, Y int
+}
+
+type Shape interface {
+	Area() int
+}
+
+type Rect struct {
+	Min, Max Point
+}
+
+func (r Rect) Area() int {
+	return (r.Max.X - r.Min.X) * (r.Max.Y - r.Min.Y)
+}
+
+type Doc struct {
+	Name   string
+	Tags   []string
+	Points map[string]Point
+	Shape  Shape
+	hidden int
+}
+
+func main() {
+	gob.RegisterName("main.Rect", Rect{})
+	d := Doc{
+		Name:   "doc",
+		Tags:   []string{"a", "b"},
+		Points: map[string]Point{"p": {1, 2}},
+		Shape:  Rect{Point{0, 0}, Point{2, 3}},
+		hidden: 100,
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&d); err != nil {
+		panic(err)
+	}
+	var v Doc
+	if err := gob.NewDecoder(&buf).Decode(&v); err != nil {
+		panic(err)
+	}
+	if v.Name != d.Name || fmt.Sprint(v.Tags) != "[a b]" || v.Points["p"] != (Point{1, 2}) || v.hidden != 0 {
+		panic(fmt.Errorf("bad decode %+v", v))
+	}
+	r, ok := v.Shape.(Rect)
+	if !ok || r != d.Shape || r.Area() != 6 {
+		panic(fmt.Errorf("bad shape %#v", v.Shape))
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeferModifyNamedResult(t *testing.T) {
+	src := `package main
+
+func f() (x int) {
+	defer func() {
+		x *= 2
+	}()
+	return 5
+}
+
+func g() (s string, err error) {
+	p := &s
+	defer func() {
+		*p += "!"
+		if r := recover(); r != nil {
+			err = r.(error)
+		}
+	}()
+	s = "hello"
+	return s + " world", nil
+}
+
+func h() (n int) {
+	for i := 0; i < 3; i++ {
+		defer func(i int) {
+			n = n*10 + i
+		}(i)
+	}
+	return 1
+}
+
+func main() {
+	if v := f(); v != 10 {
+		panic(v)
+	}
+	if s, err := g(); s != "hello world!" || err != nil {
+		panic(s)
+	}
+	if v := h(); v != 1210 {
+		panic(v)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetModFile(t *testing.T) {
+	src := `package main
+
+import "example.com/app/util"
+
+func main() {
+	if s := util.Hello("igop"); s != "hello, igop" {
+		panic(s)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	if err := ctx.SetModFile("./testdata/modfile/app/app.mod"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = igop.NewContext(0)
+	if err := ctx.SetModFile("./testdata/modfile/none.mod"); err == nil {
+		t.Fatal("must error on missing go.mod")
+	}
+}
+
+func TestShiftByTypedCount(t *testing.T) {
+	src := `package main
+
+import "runtime"
+
+type Count uint
+
+type SCount int16
+
+func shift(x int, n int) (r int, err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = e.(runtime.Error)
+		}
+	}()
+	return x << n, nil
+}
+
+func main() {
+	var n8 uint8 = 3
+	if v := 1 << n8; v != 8 {
+		panic(v)
+	}
+	if v := int64(-64) >> n8; v != -8 {
+		panic(v)
+	}
+	var c Count = 4
+	if v := uint16(1) << c; v != 16 {
+		panic(v)
+	}
+	if v := uint8(1) << (c * 2); v != 0 {
+		panic(v)
+	}
+	var s SCount = 2
+	if v := 12 >> s; v != 3 {
+		panic(v)
+	}
+	var big uint64 = 1 << 40
+	if v := 1 << big; v != 0 {
+		panic(v)
+	}
+	if v := -1 >> big; v != -1 {
+		panic(v)
+	}
+	if v, err := shift(1, 3); v != 8 || err != nil {
+		panic(v)
+	}
+	_, err := shift(1, -3)
+	if err == nil || err.Error() != "runtime error: negative shift amount" {
+		panic(err)
+	}
+	defer func() {
+		if e := recover(); e == nil || e.(error).Error() != "runtime error: negative shift amount" {
+			panic(e)
+		}
+	}()
+	s = -1
+	println(uint32(1) >> s)
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReflectStructFields(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+type Base struct {
+	ID int64 ` + "`json:\"id\"`" + `
+}
+
+type inner struct {
+	flag bool
+}
+
+type T struct {
+	Base
+	*inner
+	Name  string ` + "`json:\"name\" xml:\"n\"`" + `
+	count int8
+	Tags  []string ` + "`json:\"tags,omitempty\"`" + `
+}
+
+func main() {
+	var v T
+	typ := reflect.TypeOf(v)
+	if typ.NumField() != 5 {
+		panic(typ.NumField())
+	}
+	offsets := []uintptr{
+		unsafe.Offsetof(v.Base),
+		unsafe.Offsetof(v.inner),
+		unsafe.Offsetof(v.Name),
+		unsafe.Offsetof(v.count),
+		unsafe.Offsetof(v.Tags),
+	}
+	var infos []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Offset != offsets[i] {
+			panic(fmt.Errorf("field %v offset %v, want %v", f.Name, f.Offset, offsets[i]))
+		}
+		if len(f.Index) != 1 || f.Index[0] != i {
+			panic(fmt.Errorf("field %v index %v", f.Name, f.Index))
+		}
+		infos = append(infos, fmt.Sprintf("%v:%v:%v:%v:%q", f.Name, f.Type, f.Anonymous, f.PkgPath, f.Tag))
+	}
+	want := []string{
+		"Base:main.Base:true::\"\"",
+		"inner:*main.inner:true:main:\"\"",
+		"Name:string:false::\"json:\\\"name\\\" xml:\\\"n\\\"\"",
+		"count:int8:false:main:\"\"",
+		"Tags:[]string:false::\"json:\\\"tags,omitempty\\\"\"",
+	}
+	if fmt.Sprint(infos) != fmt.Sprint(want) {
+		panic(fmt.Errorf("fields %v, want %v", infos, want))
+	}
+	if tag := typ.Field(2).Tag; tag.Get("json") != "name" || tag.Get("xml") != "n" {
+		panic(tag)
+	}
+	f, ok := typ.FieldByName("ID")
+	if !ok || fmt.Sprint(f.Index) != "[0 0]" || f.Tag.Get("json") != "id" {
+		panic(fmt.Errorf("FieldByName ID %v", f))
+	}
+	f, ok = typ.FieldByName("flag")
+	if !ok || fmt.Sprint(f.Index) != "[1 0]" || f.PkgPath != "main" {
+		panic(fmt.Errorf("FieldByName flag %v", f))
+	}
+	v.ID = 100
+	if id := reflect.ValueOf(v).FieldByIndex([]int{0, 0}).Int(); id != 100 {
+		panic(id)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFuncToUnsafePointer(t *testing.T) {
+	_, err := igop.RunFile("main.go", `package main
+
+import "unsafe"
+
+func hello() {}
+
+func main() {
+	p := unsafe.Pointer(hello)
+	println(p)
+}
+`, nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "cannot convert") {
+		t.Fatalf("convert func to unsafe.Pointer must build error: %v", err)
+	}
+	src := `package main
+
+import (
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+type Handler struct {
+	Name string
+	Fn   func(string) string
+}
+
+func main() {
+	h := Handler{"upper", strings.ToUpper}
+	v := reflect.ValueOf(&h).Elem()
+	f := v.Field(1)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		panic("bad func field")
+	}
+	r := f.Call([]reflect.Value{reflect.ValueOf("hello")})
+	if r[0].String() != "HELLO" {
+		panic(r[0].String())
+	}
+	f.Set(reflect.ValueOf(func(s string) string {
+		return s + "!"
+	}))
+	if h.Fn("hi") != "hi!" {
+		panic("set func field")
+	}
+	if f.Pointer() == 0 {
+		panic("func pointer")
+	}
+	// indirect through a pointer to the func variable is legal
+	fp := unsafe.Pointer(&h.Fn)
+	if (*(*func(string) string)(fp))("go") != "go!" {
+		panic("func variable pointer")
+	}
+	var nilFn Handler
+	if !reflect.ValueOf(nilFn).Field(1).IsNil() {
+		panic("must nil func")
+	}
+}
+`
+	_, err = igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMultipleInterpIsolated(t *testing.T) {
+	tmpl := `package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var id = %v
+
+var count int
+
+func main() {
+	n := flag.Int("n", 0, "count")
+	flag.Parse()
+	for i := 0; i < *n; i++ {
+		count++
+		time.Sleep(time.Millisecond)
+	}
+	fmt.Println(id, count, len(os.Args), os.Args[1], flag.NArg(), flag.Arg(0))
+	os.Exit(id)
+}
+`
+	const N = 10
+	var wg sync.WaitGroup
+	errs := make([]error, N)
+	for i := 0; i < N; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			ctx := igop.NewContext(igop.SupportMultipleInterp)
+			var buf bytes.Buffer
+			if err := ctx.SetOutput(&buf); err != nil {
+				errs[id] = err
+				return
+			}
+			args := []string{fmt.Sprintf("-n=%v", id*3), fmt.Sprintf("arg%v", id)}
+			code, err := ctx.RunFile("main.go", fmt.Sprintf(tmpl, id), args)
+			ctx.SetOutput(nil)
+			if err != nil {
+				errs[id] = err
+				return
+			}
+			if code != id {
+				errs[id] = fmt.Errorf("program %v exit code %v", id, code)
+				return
+			}
+			want := fmt.Sprintf("%v %v 3 -n=%v 1 arg%v\n", id, id*3, id*3, id)
+			if buf.String() != want {
+				errs[id] = fmt.Errorf("program %v output %q, want %q", id, buf.String(), want)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFlagArgs(t *testing.T) {
+	src := `package main
+
+import (
+	"flag"
+	"os"
+)
+
+func main() {
+	n := flag.Int("n", 0, "count")
+	var v int
+	flag.CommandLine.IntVar(&v, "v", 0, "value")
+	flag.Parse()
+	if *n != 42 || v != 1 {
+		panic(*n)
+	}
+	if flag.NArg() != 1 || flag.Arg(0) != "arg" {
+		panic(flag.Args())
+	}
+	fs := flag.NewFlagSet("custom", flag.ContinueOnError)
+	m := fs.Int("n", 0, "count")
+	if err := fs.Parse(os.Args[1:]); err == nil {
+		panic("must error: flag -v not defined")
+	}
+	fs.Int("v", 0, "value")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		panic(err)
+	}
+	if *m != 42 || fs.NArg() != 1 {
+		panic(*m)
+	}
+}
+`
+	args := []string{"-n", "42", "-v=1", "arg"}
+	for _, mode := range []igop.Mode{0, igop.SupportMultipleInterp} {
+		ctx := igop.NewContext(mode)
+		var buf bytes.Buffer
+		if err := ctx.SetOutput(&buf); err != nil {
+			t.Fatal(err)
+		}
+		_, err := ctx.RunFile("main.go", src, args)
+		ctx.SetOutput(nil)
+		if err != nil {
+			t.Fatalf("mode %v: %v", mode, err)
+		}
+	}
+}
+
+func TestCopyCountAndOverlap(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+func main() {
+	dst := []int{0, 0}
+	if n := copy(dst, []int{1, 2, 3}); n != 2 || fmt.Sprint(dst) != "[1 2]" {
+		panic(fmt.Sprint(n, dst))
+	}
+	src := []int{9}
+	dst = []int{0, 0, 0}
+	if n := copy(dst, src); n != 1 || fmt.Sprint(dst) != "[9 0 0]" {
+		panic(fmt.Sprint(n, dst))
+	}
+	s := []int{1, 2, 3, 4, 5}
+	if n := copy(s[1:], s); n != 4 || fmt.Sprint(s) != "[1 1 2 3 4]" {
+		panic(fmt.Sprint(n, s))
+	}
+	s = []int{1, 2, 3, 4, 5}
+	if n := copy(s, s[2:]); n != 3 || fmt.Sprint(s) != "[3 4 5 4 5]" {
+		panic(fmt.Sprint(n, s))
+	}
+	b := []byte("hello world")
+	if n := copy(b[6:], b[:5]); n != 5 || string(b) != "hello hello" {
+		panic(fmt.Sprint(n, string(b)))
+	}
+	if n := copy(b, "HE"); n != 2 || string(b) != "HEllo hello" {
+		panic(fmt.Sprint(n, string(b)))
+	}
+	if n := copy(b[:0], "xyz"); n != 0 {
+		panic(n)
+	}
+	var nilSlice []int
+	if n := copy(nilSlice, []int{1}); n != 0 {
+		panic(n)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVirtualSignal(t *testing.T) {
+	src := `package main
+
+import (
+	"os"
+	"os/signal"
+)
+
+var Handled os.Signal
+
+func main() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	Handled = <-c
+	signal.Stop(c)
+	signal.Ignore(os.Interrupt)
+	if !signal.Ignored(os.Interrupt) {
+		panic("must ignored")
+	}
+	signal.Reset()
+	if signal.Ignored(os.Interrupt) {
+		panic("must reset")
+	}
+}
+`
+	ctx := igop.NewContext(igop.EnableVirtualSignal)
+	interp, err := ctx.LoadInterp("main.go", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := interp.RunInit(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := interp.RunMain()
+		done <- err
+	}()
+	// the signals sent before signal.Notify are dropped
+	for sent := false; !sent; {
+		interp.SendSignal(os.Interrupt)
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent = true
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	v, ok := interp.GetVarAddr("Handled")
+	if !ok || *v.(*os.Signal) != os.Interrupt {
+		t.Fatalf("handled %v", v)
+	}
+}
+
+func TestMapInterfaceKey(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+type Point struct {
+	X, Y int
+}
+
+type Pos struct {
+	X, Y int
+}
+
+type Name string
+
+func (n Name) String() string { return string(n) }
+
+type Key struct {
+	P    Point
+	Name string
+	_    int
+}
+
+func main() {
+	m := make(map[interface{}]int)
+	m[Point{1, 2}] = 1
+	m[Pos{1, 2}] = 2
+	m[Key{P: Point{1, 2}, Name: "a"}] = 3
+	m[Name("a")] = 4
+	m["a"] = 5
+	m[nil] = 6
+	if len(m) != 6 {
+		panic(fmt.Sprint("len ", len(m)))
+	}
+	if m[Point{1, 2}] != 1 || m[Pos{1, 2}] != 2 || m[Key{P: Point{1, 2}, Name: "a"}] != 3 {
+		panic("struct key")
+	}
+	if m[Name("a")] != 4 || m["a"] != 5 || m[nil] != 6 {
+		panic("basic key")
+	}
+	if _, ok := m[Point{2, 1}]; ok {
+		panic("must not found")
+	}
+	var k interface{} = Point{1, 2}
+	m[k]++
+	if m[Point{1, 2}] != 2 {
+		panic("update by interface")
+	}
+	delete(m, Pos{1, 2})
+	delete(m, nil)
+	if _, ok := m[Pos{1, 2}]; ok || len(m) != 4 {
+		panic("delete")
+	}
+	if _, ok := m[nil]; ok {
+		panic("delete nil")
+	}
+	var sum int
+	for k, v := range m {
+		switch k.(type) {
+		case Point, Key, Name, string:
+		default:
+			panic(fmt.Sprintf("bad key %T", k))
+		}
+		sum += v
+	}
+	if sum != 14 {
+		panic(sum)
+	}
+
+	p1, p2 := &Point{1, 2}, &Point{1, 2}
+	pm := map[interface{}]string{p1: "p1", p2: "p2"}
+	if len(pm) != 2 || pm[p1] != "p1" || pm[p2] != "p2" {
+		panic("pointer key")
+	}
+
+	sm := map[fmt.Stringer]int{Name("x"): 1}
+	sm[Name("x")]++
+	sm[nil] = 10
+	if len(sm) != 2 || sm[Name("x")] != 2 || sm[nil] != 10 {
+		panic("stringer key")
+	}
+
+	vm := map[string]interface{}{"a": 1}
+	vm["a"] = nil
+	if v, ok := vm["a"]; !ok || v != nil || len(vm) != 1 {
+		panic("nil value")
+	}
+	vm = map[string]interface{}{"a": nil, "b": Point{}}
+	if len(vm) != 2 {
+		panic("nil value literal")
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFunctionSignature(t *testing.T) {
+	src := `package main
+
+type Point struct {
+	X, Y int
+}
+
+func (p *Point) Move(dx int, names ...string) (Point, error) {
+	p.X += dx
+	return *p, nil
+}
+
+func add(a, b int) int {
+	return a + b
+}
+
+func main() {
+	p := &Point{}
+	p.Move(add(1, 2))
+}
+`
+	ctx := igop.NewContext(0)
+	funcs := make(map[string]*igop.Function)
+	ctx.SetCoverFunc(func(fn *igop.Function, pc int) {
+		funcs[fn.Fn.Name()] = fn
+	})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	add, move := funcs["add"], funcs["Move"]
+	if add == nil || move == nil {
+		t.Fatalf("functions not executed: %v", funcs)
+	}
+	if sig := add.Signature(); sig.Params().Len() != 2 || sig.Results().Len() != 1 || sig.String() != "func(a int, b int) int" {
+		t.Fatalf("bad add signature: %v", sig)
+	}
+	typInt := reflect.TypeOf(0)
+	if params := add.Params(); !reflect.DeepEqual(params, []reflect.Type{typInt, typInt}) {
+		t.Fatalf("bad add params: %v", params)
+	}
+	if results := add.Results(); !reflect.DeepEqual(results, []reflect.Type{typInt}) {
+		t.Fatalf("bad add results: %v", results)
+	}
+	if sig := move.Signature(); sig.Recv() == nil || !sig.Variadic() || sig.Params().Len() != 2 {
+		t.Fatalf("bad Move signature: %v", sig)
+	}
+	params := move.Params()
+	if len(params) != 3 || params[0].Kind() != reflect.Ptr || params[0].Elem().Name() != "Point" ||
+		params[1] != typInt || params[2] != reflect.TypeOf([]string(nil)) {
+		t.Fatalf("bad Move params: %v", params)
+	}
+	results := move.Results()
+	if len(results) != 2 || results[0] != params[0].Elem() || results[1] != reflect.TypeOf((*error)(nil)).Elem() {
+		t.Fatalf("bad Move results: %v", results)
+	}
+}
+
+func TestDumpFilter(t *testing.T) {
+	src := `package main
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+
+type T int
+
+func (t T) Mul(n int) int {
+	return int(t) * n
+}
+
+func main() {
+	if add(1, 2) != 3 || sub(2, 1) != 1 || T(2).Mul(3) != 6 {
+		panic("error")
+	}
+}
+`
+	f, err := os.CreateTemp(t.TempDir(), "dump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	ctx := igop.NewContext(igop.EnableDumpInstr)
+	ctx.SetDumpFilter(func(fn *ssa.Function) bool {
+		return fn.String() == "main.add" || fn.String() == "(main.T).Mul"
+	})
+	_, err = ctx.RunFile("main.go", src, nil)
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(data)
+	if !strings.Contains(dump, "func add(a int, b int) int:") {
+		t.Fatalf("missing main.add:\n%v", dump)
+	}
+	if !strings.Contains(dump, "func (t T) Mul(n int) int:") {
+		t.Fatalf("missing (main.T).Mul:\n%v", dump)
+	}
+	if strings.Count(dump, "# Name: ") != 2 {
+		t.Fatalf("must dump main.add and (main.T).Mul only:\n%v", dump)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"reflect"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// Intrinsic is the native implementation of an interpreted function. The
+// args of a method include the receiver at first.
+type Intrinsic func(args []reflect.Value) []reflect.Value
+
+// SetIntrinsic register the native implementation fn of the interpreted
+// function fullname (e.g. "main.fib" or "(*main.T).String"). Static calls of
+// the function are compiled to call fn instead of the interpreted body.
+// A nil fn removes the intrinsic.
+func (ctx *Context) SetIntrinsic(fullname string, fn Intrinsic) {
+	if fn == nil {
+		delete(ctx.intrinsics, fullname)
+		return
+	}
+	if ctx.intrinsics == nil {
+		ctx.intrinsics = make(map[string]Intrinsic)
+	}
+	ctx.intrinsics[fullname] = fn
+}
+
+// findIntrinsic returns the intrinsic of fn as a func value of the
+// function signature with receiver.
+func findIntrinsic(interp *Interp, fn *ssa.Function) (reflect.Value, bool) {
+	impl, ok := interp.ctx.intrinsics[fn.String()]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	sig := fn.Signature
+	var ins []reflect.Type
+	if recv := sig.Recv(); recv != nil {
+		ins = append(ins, interp.preToType(recv.Type()))
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		ins = append(ins, interp.preToType(sig.Params().At(i).Type()))
+	}
+	outs := make([]reflect.Type, sig.Results().Len())
+	for i := range outs {
+		outs[i] = interp.preToType(sig.Results().At(i).Type())
+	}
+	typ := reflect.FuncOf(ins, outs, sig.Variadic())
+	return reflect.MakeFunc(typ, impl), true
+}
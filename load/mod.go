@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package load
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+)
+
+// ModLookup returns the lookup of the import path resolved by the go.mod file:
+// the packages of the main module, the local replace directives and the
+// required modules in the module cache.
+func ModLookup(file string) (func(path string) (dir string, found bool), error) {
+	f, err := ParseModFile(file)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return nil, err
+	}
+	mods := map[string]string{
+		f.Module.Mod.Path: root,
+	}
+	for _, r := range f.Require {
+		mods[r.Mod.Path] = modCacheDir(r.Mod)
+	}
+	for _, r := range f.Replace {
+		if _, ok := mods[r.Old.Path]; !ok {
+			continue
+		}
+		if r.Old.Version != "" && r.Old.Version != requireVersion(f.Require, r.Old.Path) {
+			continue
+		}
+		if r.New.Version == "" {
+			dir := r.New.Path
+			if !filepath.IsAbs(dir) {
+				dir = filepath.Join(root, dir)
+			}
+			mods[r.Old.Path] = dir
+		} else {
+			mods[r.Old.Path] = modCacheDir(r.New)
+		}
+	}
+	paths := make([]string, 0, len(mods))
+	for path := range mods {
+		paths = append(paths, path)
+	}
+	// longest module path first
+	sort.Slice(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+	return func(path string) (dir string, found bool) {
+		for _, mod := range paths {
+			if mods[mod] == "" {
+				continue
+			}
+			if path == mod {
+				dir = mods[mod]
+			} else if strings.HasPrefix(path, mod+"/") {
+				dir = filepath.Join(mods[mod], filepath.FromSlash(path[len(mod)+1:]))
+			} else {
+				continue
+			}
+			if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+				return dir, true
+			}
+			return "", false
+		}
+		return "", false
+	}, nil
+}
+
+func requireVersion(list []*modfile.Require, path string) string {
+	for _, r := range list {
+		if r.Mod.Path == path {
+			return r.Mod.Version
+		}
+	}
+	return ""
+}
+
+// modCacheDir returns the directory of module m in the module cache.
+func modCacheDir(m module.Version) string {
+	cache := os.Getenv("GOMODCACHE")
+	if cache == "" {
+		list := filepath.SplitList(build.Default.GOPATH)
+		if len(list) == 0 {
+			return ""
+		}
+		cache = filepath.Join(list[0], "pkg", "mod")
+	}
+	path, err := module.EscapePath(m.Path)
+	if err != nil {
+		return ""
+	}
+	version, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(cache, path+"@"+version)
+}
@@ -87,6 +87,24 @@ func findModule(dir string) (file string, found bool) {
 	return
 }
 
+// GoVersion returns the go directive version of the module contains dir,
+// e.g. "go1.22". Returns empty if not found.
+func GoVersion(dir string) string {
+	dir, err := absDir(dir)
+	if err != nil {
+		return ""
+	}
+	mod, found := findModule(dir)
+	if !found {
+		return ""
+	}
+	f, err := ParseModFile(mod)
+	if err != nil || f.Go == nil {
+		return ""
+	}
+	return "go" + f.Go.Version
+}
+
 // ParseModFile parse go.mod
 func ParseModFile(file string) (*modfile.File, error) {
 	data, err := ioutil.ReadFile(file)
@@ -22,6 +22,7 @@ import (
 	"go/types"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -202,6 +203,55 @@ func (p *function) PositionForPC(pc int) token.Position {
 	return p.Fn.Prog.Fset.Position(pos)
 }
 
+// SourceLines returns the sorted source lines covered by the function instructions.
+func (p *function) SourceLines() []int {
+	fset := p.Fn.Prog.Fset
+	var lines []int
+	seen := make(map[int]bool)
+	for pc := range p.ssaInstrs {
+		pos := p.PosForPC(pc)
+		if !pos.IsValid() {
+			continue
+		}
+		line := fset.Position(pos).Line
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Signature returns the signature of the function.
+func (p *function) Signature() *types.Signature {
+	return p.Fn.Signature
+}
+
+// Params returns the parameter types of the function, the receiver of a
+// method is the first parameter.
+func (p *function) Params() []reflect.Type {
+	sig := p.Fn.Signature
+	var list []reflect.Type
+	if recv := sig.Recv(); recv != nil {
+		list = append(list, p.Interp.toType(recv.Type()))
+	}
+	return append(list, p.tupleTypes(sig.Params())...)
+}
+
+// Results returns the result types of the function.
+func (p *function) Results() []reflect.Type {
+	return p.tupleTypes(p.Fn.Signature.Results())
+}
+
+func (p *function) tupleTypes(tuple *types.Tuple) []reflect.Type {
+	list := make([]reflect.Type, tuple.Len())
+	for i := range list {
+		list[i] = p.Interp.toType(tuple.At(i).Type())
+	}
+	return list
+}
+
 func (p *function) regIndex3(v ssa.Value) (register, kind, value) {
 	instr := p.regInstr(v)
 	index := int(instr & 0xffffff)
@@ -334,6 +384,11 @@ func checkFuncCompatible(t1, t2 reflect.Type) bool {
 
 func findExternFunc(interp *Interp, fn *ssa.Function) (ext reflect.Value, ok bool) {
 	fnName := fn.String()
+	defer func() {
+		if ok && interp.ctx.faults != nil {
+			ext = interp.ctx.wrapFault(fnName, ext)
+		}
+	}()
 	ext, ok = findExternValue(interp, fnName)
 	if ok {
 		typ := interp.preToType(fn.Type())
@@ -354,6 +409,19 @@ func findExternFunc(interp *Interp, fn *ssa.Function) (ext reflect.Value, ok boo
 			}
 		}
 	}
+	if !ok && interp.ctx.unresolved != nil && !(fn.Pkg != nil && fn.Name() == "init") {
+		// check unresolved handler, skip pkg.init
+		ext, ok = interp.ctx.unresolved(fnName, fn.Signature)
+		if ok {
+			typ := interp.preToType(fn.Type())
+			ftyp := ext.Type()
+			if typ != ftyp && checkFuncCompatible(typ, ftyp) {
+				ext = xtype.ConvertFunc(ext, xtype.TypeOfType(typ))
+			}
+			// cache the resolved func for the later lookups
+			interp.ctx.override[fnName] = ext
+		}
+	}
 	return
 }
 
@@ -399,6 +467,12 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 	case *ssa.Call:
 		return makeCallInstr(pfn, interp, instr, &instr.Call)
 	case *ssa.BinOp:
+		if (instr.Op == token.SHL || instr.Op == token.SHR) && isNegativeConst(instr.Y) {
+			// panic at run time, not on building the constant shift
+			return func(fr *frame) {
+				panic(RuntimeError("negative shift amount"))
+			}
+		}
 		switch instr.Op {
 		case token.ADD:
 			return makeBinOpADD(pfn, instr)
@@ -466,16 +540,19 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		typ := interp.preToType(instr.Type())
 		ir := pfn.regIndex(instr)
 		ix, kx, vx := pfn.regIndex3(instr.X)
+		// boxing a typed nil value must produce a non-nil interface
+		zero := reflect.Zero(interp.preToType(instr.X.Type()))
 		if kx.isStatic() {
+			if vx == nil {
+				vx = zero.Interface()
+			}
 			if typ == tyEmptyInterface {
 				return func(fr *frame) {
 					fr.setReg(ir, vx)
 				}
 			}
 			v := reflect.New(typ).Elem()
-			if vx != nil {
-				SetValue(v, reflect.ValueOf(vx))
-			}
+			SetValue(v, reflect.ValueOf(vx))
 			vx = v.Interface()
 			return func(fr *frame) {
 				fr.setReg(ir, vx)
@@ -483,14 +560,19 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		}
 		if typ == tyEmptyInterface {
 			return func(fr *frame) {
-				fr.setReg(ir, fr.reg(ix))
+				x := fr.reg(ix)
+				if x == nil {
+					x = zero.Interface()
+				}
+				fr.setReg(ir, x)
 			}
 		}
 		return func(fr *frame) {
 			v := reflect.New(typ).Elem()
 			if x := fr.reg(ix); x != nil {
-				vx := reflect.ValueOf(x)
-				SetValue(v, vx)
+				SetValue(v, reflect.ValueOf(x))
+			} else {
+				SetValue(v, zero)
 			}
 			fr.setReg(ir, v.Interface())
 		}
@@ -553,13 +635,17 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		ir := pfn.regIndex(instr)
 		il := pfn.regIndex(instr.Len)
 		ic := pfn.regIndex(instr.Cap)
+		maxLen := maxMemLen
+		if interp.ctx.intSize == 32 {
+			maxLen = maxMemLen32
+		}
 		return func(fr *frame) {
 			Len := asInt(fr.reg(il))
-			if Len < 0 || Len >= maxMemLen {
+			if Len < 0 || Len >= maxLen {
 				panic(fr.runtimeError(instr, "makeslice: len out of range"))
 			}
 			Cap := asInt(fr.reg(ic))
-			if Cap < 0 || Cap >= maxMemLen {
+			if Cap < 0 || Cap >= maxLen {
 				panic(fr.runtimeError(instr, "makeslice: cap out of range"))
 			}
 			fr.setReg(ir, reflect.MakeSlice(typ, Len, Cap).Interface())
@@ -617,6 +703,8 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 			case reflect.Array:
 			case reflect.Invalid:
 				panic(fr.runtimeError(instr, "invalid memory address or nil pointer dereference"))
+			case reflect.Map:
+				panic(fmt.Errorf("cannot take address of map element: %v", instr))
 			default:
 				panic(fmt.Sprintf("unexpected x type in IndexAddr: %T", x))
 			}
@@ -661,7 +749,7 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 				m := fr.reg(ix)
 				idx := fr.reg(ii)
 				vm := reflect.ValueOf(m)
-				v := vm.MapIndex(reflect.ValueOf(idx))
+				v := vm.MapIndex(mapValue(idx, typ.Key()))
 				ok := v.IsValid()
 				var rv value
 				if ok {
@@ -718,7 +806,17 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 					Send: send,
 				})
 			}
+			if instr.Blocking {
+				// the blocked select ends by Abort
+				cases = append(cases, reflect.SelectCase{
+					Dir:  reflect.SelectRecv,
+					Chan: reflect.ValueOf(interp.done),
+				})
+			}
 			chosen, recv, recvOk := reflect.Select(cases)
+			if instr.Blocking && chosen == len(instr.States) {
+				panic(abortPanic(0))
+			}
 			if !instr.Blocking {
 				chosen-- // default case should have index -1.
 			}
@@ -925,30 +1023,29 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		return func(fr *frame) {
 			fn, args := interp.prepareCall(fr, &instr.Call, iv, ia, ib)
 			atomic.AddInt32(&interp.goroutines, 1)
-			if interp.ctx.RunContext != nil {
-				go func() {
-					root := &frame{interp: interp}
-					switch f := fn.(type) {
-					case *ssa.Function:
-						root.pfn = interp.funcs[f]
-					case *closure:
-						root.pfn = f.pfn
-					}
-					defer func() {
-						e := recover()
-						if e != nil {
-							interp.cherror <- PanicError{stack: debugStack(root), Value: e}
-						}
-					}()
-					interp.callDiscardsResult(root, fn, args, instr.Call.Args)
-					atomic.AddInt32(&interp.goroutines, -1)
-				}()
-			} else {
-				go func() {
-					interp.callDiscardsResult(&frame{}, fn, args, instr.Call.Args)
+			go func() {
+				root := &frame{interp: interp}
+				switch f := fn.(type) {
+				case *ssa.Function:
+					root.pfn = interp.funcs[f]
+				case *closure:
+					root.pfn = f.pfn
+				}
+				gid := goroutineID()
+				interp.goids.Store(gid, struct{}{})
+				defer func() {
+					interp.goids.Delete(gid)
 					atomic.AddInt32(&interp.goroutines, -1)
+					switch e := recover().(type) {
+					case nil, goexitPanic, abortPanic:
+					case PanicError:
+						interp.goPanic(e)
+					default:
+						interp.goPanic(PanicError{stack: debugStack(root), Value: e})
+					}
 				}()
-			}
+				interp.callDiscardsResult(root, fn, args, instr.Call.Args)
+			}()
 		}
 	case *ssa.Defer:
 		iv, ia, ib := getCallIndex(pfn, &instr.Call)
@@ -969,15 +1066,15 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 			x := fr.reg(ix)
 			ch := reflect.ValueOf(c)
 			if x == nil {
-				ch.Send(reflect.New(ch.Type().Elem()).Elem())
+				interp.chanSend(ch, reflect.New(ch.Type().Elem()).Elem())
 			} else {
-				ch.Send(reflect.ValueOf(x))
+				interp.chanSend(ch, reflect.ValueOf(x))
 			}
 		}
 	case *ssa.Store:
 		// skip struct field _
 		if addr, ok := instr.Addr.(*ssa.FieldAddr); ok {
-			if s, ok := addr.X.Type().(*types.Pointer).Elem().(*types.Struct); ok {
+			if s, ok := addr.X.Type().Underlying().(*types.Pointer).Elem().Underlying().(*types.Struct); ok {
 				if s.Field(addr.Field).Name() == "_" {
 					return nil
 				}
@@ -985,6 +1082,17 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		}
 		if pfn.Fn.Name() == "init" && pfn.Fn.Synthetic == "package initializer" {
 			pfn.Interp.chkinit[instr.Addr.String()] = true
+			// the link value replaces the constant initial value like the linker
+			if g, ok := instr.Addr.(*ssa.Global); ok {
+				if value, ok := pfn.Interp.ctx.linkValues[g.String()]; ok {
+					if _, ok := instr.Val.(*ssa.Const); ok && isStringType(instr.Val.Type()) {
+						ia := pfn.regIndex(instr.Addr)
+						return func(fr *frame) {
+							reflect.ValueOf(fr.reg(ia)).Elem().SetString(value)
+						}
+					}
+				}
+			}
 		}
 		ia := pfn.regIndex(instr.Addr)
 		iv, kv, vv := pfn.regIndex3(instr.Val)
@@ -1014,18 +1122,21 @@ func makeInstr(interp *Interp, pfn *function, instr ssa.Instruction) func(fr *fr
 		im := pfn.regIndex(instr.Map)
 		ik := pfn.regIndex(instr.Key)
 		iv, kv, vv := pfn.regIndex3(instr.Value)
+		typ := interp.preToType(instr.Map.Type())
+		ktyp, etyp := typ.Key(), typ.Elem()
 		if kv.isStatic() {
+			v := mapValue(vv, etyp)
 			return func(fr *frame) {
 				vm := reflect.ValueOf(fr.reg(im))
-				vk := reflect.ValueOf(fr.reg(ik))
-				vm.SetMapIndex(vk, reflect.ValueOf(vv))
+				vk := mapValue(fr.reg(ik), ktyp)
+				vm.SetMapIndex(vk, v)
 			}
 		}
 		return func(fr *frame) {
 			vm := reflect.ValueOf(fr.reg(im))
-			vk := reflect.ValueOf(fr.reg(ik))
+			vk := mapValue(fr.reg(ik), ktyp)
 			v := fr.reg(iv)
-			vm.SetMapIndex(vk, reflect.ValueOf(v))
+			vm.SetMapIndex(vk, mapValue(v, etyp))
 		}
 	case *ssa.DebugRef:
 		if v, ok := instr.Object().(*types.Var); ok {
@@ -1110,6 +1221,11 @@ func makeCallInstr(pfn *function, interp *Interp, instr ssa.Value, call *ssa.Cal
 		}
 	case *ssa.Function:
 		// "static func/method call"
+		if ext, ok := findIntrinsic(interp, fn); ok {
+			return func(fr *frame) {
+				interp.callExternalByStack(fr, ext, ir, ia)
+			}
+		}
 		if fn.Blocks == nil {
 			ext, ok := findExternFunc(interp, fn)
 			if !ok {
@@ -1249,7 +1365,7 @@ func makeCallMethodInstr(interp *Interp, instr ssa.Value, call *ssa.CallCommon,
 	return func(fr *frame) {
 		v := fr.reg(iv)
 		if v == nil {
-			panic(fr.runtimeError(instr, "runtime error: invalid memory address or nil pointer dereference"))
+			panic(fr.runtimeError(instr, "invalid memory address or nil pointer dereference"))
 		}
 		rtype := reflect.TypeOf(v)
 		// find user type method *ssa.Function
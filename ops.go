@@ -85,6 +85,15 @@ func constToValue(i *Interp, c *ssa.Const) value {
 }
 
 func globalToValue(i *Interp, key *ssa.Global) (interface{}, bool) {
+	// check override variable address
+	if ext, ok := i.ctx.override[key.String()]; ok && ext.Kind() == reflect.Ptr {
+		return ext.Interface(), true
+	}
+	if i.isolated() {
+		if v, ok := i.isolatedVar(key.String()); ok {
+			return v, true
+		}
+	}
 	if key.Pkg != nil {
 		pkgpath := key.Pkg.Pkg.Path()
 		if pkg, ok := i.installed(pkgpath); ok {
@@ -147,6 +156,71 @@ func asInt(x value) int {
 	panic(fmt.Sprintf("cannot convert %T to int", x))
 }
 
+// makeIntSize32Instr wraps fn to truncate the int or uint result of instr to
+// 32 bits, simulating a 32-bit target on a 64-bit host. The results of calls
+// are truncated too, the external functions return 64 bits. uintptr is left
+// alone, it may hold an address.
+func makeIntSize32Instr(pfn *function, instr ssa.Instruction, fn func(fr *frame)) func(fr *frame) {
+	switch instr := instr.(type) {
+	case *ssa.Convert:
+		if isUnsafePointer(instr.X.Type()) || isUnsafePointer(instr.Type()) {
+			return fn
+		}
+	case *ssa.BinOp, *ssa.UnOp, *ssa.Call, *ssa.Extract:
+	default:
+		return fn
+	}
+	v := instr.(ssa.Value)
+	t, ok := v.Type().Underlying().(*types.Basic)
+	if !ok {
+		return fn
+	}
+	switch t.Kind() {
+	case types.Int, types.Uint:
+	default:
+		return fn
+	}
+	ir := pfn.regIndex(v)
+	return func(fr *frame) {
+		fn(fr)
+		fr.setReg(ir, truncInt32(fr.reg(ir)))
+	}
+}
+
+// isUnsafePointer reports whether t is unsafe.Pointer.
+func isUnsafePointer(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Kind() == types.UnsafePointer
+}
+
+// isStringType reports whether the underlying type of t is string.
+func isStringType(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&types.IsString != 0
+}
+
+// truncInt32 truncates x, which must be an int or uint, to 32 bits.
+func truncInt32(x value) value {
+	switch x := x.(type) {
+	case int:
+		return int(int32(x))
+	case uint:
+		return uint(uint32(x))
+	default:
+		v := reflect.ValueOf(x)
+		r := reflect.New(v.Type()).Elem()
+		switch v.Kind() {
+		case reflect.Int:
+			r.SetInt(int64(int32(v.Int())))
+		case reflect.Uint:
+			r.SetUint(uint64(uint32(v.Uint())))
+		default:
+			return x
+		}
+		return r.Interface()
+	}
+}
+
 // asUint64 converts x, which must be an unsigned integer, to a uint64
 // suitable for use as a bitwise shift count.
 func asUint64(x value) uint64 {
@@ -1075,6 +1149,21 @@ func binop(instr *ssa.BinOp, t types.Type, x, y value) value {
 	panic(fmt.Sprintf("invalid binary op: %T %s %T", x, instr.Op, y))
 }
 
+// isNegativeConst reports whether v is a negative integer constant.
+func isNegativeConst(v ssa.Value) bool {
+	c, ok := v.(*ssa.Const)
+	return ok && c.Value != nil && c.Value.Kind() == constant.Int && constant.Sign(c.Value) < 0
+}
+
+// mapValue returns the reflect.Value of map key or element x of type typ.
+// The nil interface is held as nil, it is the zero value of typ.
+func mapValue(x value, typ reflect.Type) reflect.Value {
+	if x == nil {
+		return reflect.Zero(typ)
+	}
+	return reflect.ValueOf(x)
+}
+
 func IsConstNil(v ssa.Value) bool {
 	switch c := v.(type) {
 	case *ssa.Const:
@@ -1306,6 +1395,28 @@ failed:
 	panic(fmt.Sprintf("invalid unary op %s %T", instr.Op, x))
 }
 
+// missingPointerMethod returns the method of interface typ only implemented by *rt.
+func (i *Interp) missingPointerMethod(rt reflect.Type, typ reflect.Type) string {
+	if typ.Kind() != reflect.Interface || rt.Kind() == reflect.Ptr {
+		return ""
+	}
+	mset, ok := i.msets[rt]
+	if !ok {
+		return ""
+	}
+	pset := i.msets[reflect.PtrTo(rt)]
+	for n := 0; n < typ.NumMethod(); n++ {
+		name := typ.Method(n).Name
+		if _, ok := mset[name]; ok {
+			continue
+		}
+		if _, ok := pset[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
 // typeAssert checks whether dynamic type of itf is instr.AssertedType.
 // It returns the extracted value on success, and panics on failure,
 // unless instr.CommaOk, in which case it always returns a "value,ok" tuple.
@@ -1328,11 +1439,11 @@ func typeAssert(fr *frame, instr *ssa.TypeAssert, typ reflect.Type, xtyp reflect
 			v = iv
 		} else {
 			if !rt.AssignableTo(typ) {
-				err = fr.runtimeError(instr, fmt.Sprintf("interface conversion: %v is %v, not %v", xtyp, rt, typ))
+				err = fr.plainError(instr, fmt.Sprintf("interface conversion: %v is %v, not %v", xtyp, rt, typ))
 				if itype, ok := instr.AssertedType.Underlying().(*types.Interface); ok {
 					if it, ok := fr.interp.findType(rt, false); ok {
 						if meth, _ := types.MissingMethod(it, itype, true); meth != nil {
-							err = fr.runtimeError(instr, fmt.Sprintf("interface conversion: %v is not %v: missing method %s",
+							err = fr.plainError(instr, fmt.Sprintf("interface conversion: %v is not %v: missing method %s",
 								rt, instr.AssertedType, meth.Name()))
 						}
 					}
@@ -1343,10 +1454,13 @@ func typeAssert(fr *frame, instr *ssa.TypeAssert, typ reflect.Type, xtyp reflect
 						n1, ok1 := t1.(*types.Named)
 						n2, ok2 := t2.(*types.Named)
 						if ok1 && ok2 && n1.Obj().Parent() != n2.Obj().Parent() {
-							err = fr.runtimeError(instr, fmt.Sprintf("interface conversion: %v is %v, not %v (types from different scopes)", xtyp, rt, typ))
+							err = fr.plainError(instr, fmt.Sprintf("interface conversion: %v is %v, not %v (types from different scopes)", xtyp, rt, typ))
 						}
 					}
 				}
+			} else if name := fr.interp.missingPointerMethod(rt, typ); name != "" {
+				err = fr.plainError(instr, fmt.Sprintf("interface conversion: %v is not %v: missing method %s",
+					rt, instr.AssertedType, name))
 			} else {
 				v = rv.Convert(typ).Interface()
 			}
@@ -0,0 +1,248 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// outputFile is the interpreted os.Stdout/os.Stderr file, writes to a
+// non-file writer are forwarded through a pipe, the pipe is opened for each
+// run, flushed when the interp returns and closed at the end of the run.
+type outputFile struct {
+	w       io.Writer
+	file    *os.File      // the interpreted file
+	done    chan struct{} // pipe copy done, nil if not a pipe
+	mark    []byte        // flush mark written to the pipe
+	flushed chan struct{} // flush mark copied
+	mu      sync.Mutex    // serializes Flush
+	restore func()        // restore the overrides replaced
+}
+
+func newOutputFile(w io.Writer) (*outputFile, error) {
+	f := &outputFile{w: w}
+	if file, ok := w.(*os.File); ok {
+		f.file = file
+	}
+	return f, f.open()
+}
+
+// open opens the pipe forwarding writes to the non-file writer if closed.
+func (f *outputFile) open() error {
+	if f.file != nil {
+		return nil
+	}
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	mark := make([]byte, 16)
+	rand.New(rand.NewSource(time.Now().UnixNano())).Read(mark)
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		copyOutput(f.w, r, mark, flushed)
+		r.Close()
+		close(done)
+	}()
+	f.file, f.done, f.mark, f.flushed = pw, done, mark, flushed
+	return nil
+}
+
+// copyOutput copies r to w until EOF, the flush marks read from r are
+// dropped and reported to flushed.
+func copyOutput(w io.Writer, r io.Reader, mark []byte, flushed chan<- struct{}) {
+	buf := make([]byte, 32*1024)
+	var data []byte
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		for {
+			i := bytes.Index(data, mark)
+			if i < 0 {
+				break
+			}
+			if i > 0 {
+				w.Write(data[:i])
+			}
+			data = data[i+len(mark):]
+			flushed <- struct{}{}
+		}
+		if err != nil {
+			if len(data) > 0 {
+				w.Write(data)
+			}
+			return
+		}
+		// keep the tail that may be the start of a mark
+		k := len(mark) - 1
+		if k > len(data) {
+			k = len(data)
+		}
+		for ; k > 0 && !bytes.HasPrefix(mark, data[len(data)-k:]); k-- {
+		}
+		if k < len(data) {
+			w.Write(data[:len(data)-k])
+			data = append([]byte(nil), data[len(data)-k:]...)
+		}
+	}
+}
+
+// Flush waits for the data written to the pipe to be written.
+func (f *outputFile) Flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done == nil {
+		return
+	}
+	if _, err := f.file.Write(f.mark); err != nil {
+		return
+	}
+	select {
+	case <-f.flushed:
+	case <-f.done:
+	}
+}
+
+// Close closes the pipe and waits for all data to be written.
+func (f *outputFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done == nil {
+		return nil
+	}
+	err := f.file.Close()
+	<-f.done
+	f.file, f.done = nil, nil
+	return err
+}
+
+func (f *outputFile) Write(p []byte) (int, error) {
+	return f.file.Write(p)
+}
+
+// openOutput opens the output pipes closed by the previous run.
+func (ctx *Context) openOutput() error {
+	for _, f := range []*outputFile{ctx.stdout, ctx.stderr} {
+		if f != nil {
+			if err := f.open(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flushOutput waits for the output written to the pipes to be written.
+func (ctx *Context) flushOutput() {
+	for _, f := range []*outputFile{ctx.stdout, ctx.stderr} {
+		if f != nil {
+			f.Flush()
+		}
+	}
+}
+
+// closeOutput flushes and closes the output pipes at the end of a run.
+func (ctx *Context) closeOutput() {
+	for _, f := range []*outputFile{ctx.stdout, ctx.stderr} {
+		if f != nil {
+			f.Close()
+		}
+	}
+}
+
+// setOutputFile replaces the output file *pf by a file of w and set the
+// overrides funcs returns, a nil w restores the overrides replaced.
+func (ctx *Context) setOutputFile(pf **outputFile, w io.Writer, funcs func(out *outputFile) map[string]reflect.Value) error {
+	var restore func()
+	if *pf != nil {
+		(*pf).Close()
+		restore = (*pf).restore
+		*pf = nil
+	}
+	if w == nil {
+		if restore != nil {
+			restore()
+		}
+		return nil
+	}
+	out, err := newOutputFile(w)
+	if err != nil {
+		if restore != nil {
+			restore()
+		}
+		return err
+	}
+	if restore == nil {
+		restore = ctx.replaceOverride(funcs(out))
+	} else {
+		for name, fn := range funcs(out) {
+			ctx.override[name] = fn
+		}
+	}
+	out.restore = restore
+	*pf = out
+	return nil
+}
+
+// SetOutput set the interpreted standard output, includes os.Stdout and the
+// fmt.Print/Printf/Println functions, and builtin print/println if the print
+// output is not set by SetPrintOutput. Writes to a non-file writer are
+// forwarded through a pipe, which is flushed before the interp returns.
+// A nil w restores the host os.Stdout and the overrides registered before.
+func (ctx *Context) SetOutput(w io.Writer) error {
+	return ctx.setOutputFile(&ctx.stdout, w, func(out *outputFile) map[string]reflect.Value {
+		return map[string]reflect.Value{
+			"os.Stdout": reflect.ValueOf(&out.file),
+			"fmt.Print": reflect.ValueOf(func(a ...interface{}) (int, error) {
+				return fmt.Fprint(out.file, a...)
+			}),
+			"fmt.Printf": reflect.ValueOf(func(format string, a ...interface{}) (int, error) {
+				return fmt.Fprintf(out.file, format, a...)
+			}),
+			"fmt.Println": reflect.ValueOf(func(a ...interface{}) (int, error) {
+				return fmt.Fprintln(out.file, a...)
+			}),
+		}
+	})
+}
+
+// SetErrOutput set the interpreted standard error, includes os.Stderr and
+// runtime/debug.PrintStack. Writes to a non-file writer are forwarded through
+// a pipe, which is flushed before the interp returns.
+// A nil w restores the host os.Stderr and the override registered before.
+func (ctx *Context) SetErrOutput(w io.Writer) error {
+	return ctx.setOutputFile(&ctx.stderr, w, func(out *outputFile) map[string]reflect.Value {
+		return map[string]reflect.Value{
+			"os.Stderr": reflect.ValueOf(&out.file),
+		}
+	})
+}
+
+func (ctx *Context) writeErrOutput(data []byte) (n int, err error) {
+	if ctx.stderr != nil {
+		return ctx.stderr.Write(data)
+	}
+	return os.Stderr.Write(data)
+}
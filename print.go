@@ -56,7 +56,7 @@ func writevalue(buf *bytes.Buffer, v interface{}, enableAny bool) {
 			fmt.Fprintf(buf, "(%p,%p)", eface.typ, eface.word)
 		case reflect.Struct, reflect.Array:
 			if enableAny {
-				fmt.Fprintf(buf, "%v", v)
+				writeaggregate(buf, i)
 			} else {
 				panic(fmt.Sprintf("illegal types for operand: print\n\t%T", v))
 			}
@@ -66,6 +66,46 @@ func writevalue(buf *bytes.Buffer, v interface{}, enableAny bool) {
 	}
 }
 
+// writeaggregate write struct or array value fields recursively
+func writeaggregate(buf *bytes.Buffer, v reflect.Value) {
+	if !v.CanAddr() {
+		nv := reflect.New(v.Type()).Elem()
+		nv.Set(v)
+		v = nv
+	}
+	var open, close byte = '{', '}'
+	if v.Kind() == reflect.Array {
+		open, close = '[', ']'
+	}
+	buf.WriteByte(open)
+	var n int
+	if v.Kind() == reflect.Struct {
+		n = v.NumField()
+	} else {
+		n = v.Len()
+	}
+	for j := 0; j < n; j++ {
+		if j > 0 {
+			buf.WriteByte(' ')
+		}
+		var f reflect.Value
+		if v.Kind() == reflect.Struct {
+			f = v.Field(j)
+		} else {
+			f = v.Index(j)
+		}
+		// access unexported fields by address
+		f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+		switch f.Kind() {
+		case reflect.Struct, reflect.Array:
+			writeaggregate(buf, f)
+		default:
+			writevalue(buf, f.Interface(), true)
+		}
+	}
+	buf.WriteByte(close)
+}
+
 // emptyInterface is the header for an interface{} value.
 type emptyInterface struct {
 	typ  unsafe.Pointer
@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"math/rand"
+	"reflect"
+	"sync"
+)
+
+// SetRandSeed set the seed of the math/rand global source used by interpreted
+// programs, every run of the context produces the same random sequence.
+func (ctx *Context) SetRandSeed(seed int64) {
+	src := &lockedSource{src: rand.NewSource(seed).(rand.Source64)}
+	ctx.rand = rand.New(src)
+	ctx.randSeed = seed
+	funcs := make(map[string]reflect.Value)
+	for name, fn := range randFuncs(ctx.rand) {
+		funcs[name] = reflect.ValueOf(fn)
+	}
+	if ctx.randRestore == nil {
+		ctx.randRestore = ctx.replaceOverride(funcs)
+	} else {
+		for name, fn := range funcs {
+			ctx.override[name] = fn
+		}
+	}
+}
+
+// ClearRandSeed clear the seed set by SetRandSeed, restores the host math/rand
+// global source and the overrides of the math/rand functions registered before.
+func (ctx *Context) ClearRandSeed() {
+	if ctx.randRestore != nil {
+		ctx.randRestore()
+		ctx.randRestore = nil
+	}
+	ctx.rand = nil
+	ctx.randSeed = 0
+}
+
+// resetRand reseeds the source set by SetRandSeed at the start of a run.
+func (ctx *Context) resetRand() {
+	if ctx.rand != nil {
+		ctx.rand.Seed(ctx.randSeed)
+	}
+}
+
+// lockedSource is a rand.Source64 safe for concurrent use like the host global source.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (r *lockedSource) Int63() (n int64) {
+	r.mu.Lock()
+	n = r.src.Int63()
+	r.mu.Unlock()
+	return
+}
+
+func (r *lockedSource) Uint64() (n uint64) {
+	r.mu.Lock()
+	n = r.src.Uint64()
+	r.mu.Unlock()
+	return
+}
+
+func (r *lockedSource) Seed(seed int64) {
+	r.mu.Lock()
+	r.src.Seed(seed)
+	r.mu.Unlock()
+}
+
+func randFuncs(r *rand.Rand) map[string]interface{} {
+	return map[string]interface{}{
+		"math/rand.ExpFloat64":  r.ExpFloat64,
+		"math/rand.Float32":     r.Float32,
+		"math/rand.Float64":     r.Float64,
+		"math/rand.Int":         r.Int,
+		"math/rand.Int31":       r.Int31,
+		"math/rand.Int31n":      r.Int31n,
+		"math/rand.Int63":       r.Int63,
+		"math/rand.Int63n":      r.Int63n,
+		"math/rand.Intn":        r.Intn,
+		"math/rand.NormFloat64": r.NormFloat64,
+		"math/rand.Perm":        r.Perm,
+		"math/rand.Read":        r.Read,
+		"math/rand.Seed":        r.Seed,
+		"math/rand.Shuffle":     r.Shuffle,
+		"math/rand.Uint32":      r.Uint32,
+		"math/rand.Uint64":      r.Uint64,
+	}
+}
@@ -0,0 +1,545 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+)
+
+// The ssa builder does not support range-over-func, so the type checked
+// files are rewritten before build:
+//
+//	for k, v := range seq {
+//		body
+//	}
+//
+// to
+//
+//	{
+//		var ctl int
+//		var done bool
+//		seq(func(k K, v V) bool {
+//			if done {
+//				panic(...)
+//			}
+//			body // break => return false, continue => return true
+//			return true
+//		})
+//		done = true
+//		if ctl == 1 { return }
+//		...
+//	}
+//
+// return, break and continue to outer labels set ctl and are executed after
+// the iterator call. defer and goto out of the loop body are not supported.
+
+const errRangeFuncContinued = "runtime error: range function continued iteration after function for loop body returned false"
+
+const (
+	ctlReturn      = 1 // bare return
+	ctlReturnValue = 2 // return with result values
+	ctlLabel       = 3 // first break/continue label
+)
+
+// rewriteRangeFunc rewrite the range-over-func loops of files, it reports
+// whether files is changed.
+func rewriteRangeFunc(fset *token.FileSet, pkg *types.Package, info *types.Info, files []*ast.File) (bool, error) {
+	changed := false
+	for _, file := range files {
+		r := &rangeFuncRewriter{fset: fset, pkg: pkg, info: info, file: file}
+		ast.Inspect(file, func(n ast.Node) bool {
+			if r.err != nil {
+				return false
+			}
+			switch n := n.(type) {
+			case *ast.FuncDecl:
+				if n.Body != nil {
+					if fn, ok := info.Defs[n.Name].(*types.Func); ok {
+						r.rewriteFunc(fn.Type().(*types.Signature), n.Body)
+					}
+				}
+			case *ast.FuncLit:
+				if sig, ok := info.TypeOf(n).(*types.Signature); ok {
+					r.rewriteFunc(sig, n.Body)
+				}
+			}
+			return true
+		})
+		if r.err != nil {
+			return false, r.err
+		}
+		if r.index > 0 {
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+type rangeFuncRewriter struct {
+	fset    *token.FileSet
+	pkg     *types.Package
+	info    *types.Info
+	file    *ast.File
+	imports map[string]string // path -> name of added imports
+	index   int
+	err     error
+}
+
+// rangeFuncLoop is the state of rewriting one range-over-func loop.
+type rangeFuncLoop struct {
+	index   int
+	label   string
+	sig     *types.Signature // enclosing function
+	labels  map[string]bool  // labels defined in body
+	ctl     bool
+	bare    bool              // has bare return
+	results bool              // has return with result values
+	targets []*ast.BranchStmt // break/continue to outer labels
+}
+
+func (r *rangeFuncRewriter) errorf(pos token.Pos, format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = fmt.Errorf("%v: %v", r.fset.Position(pos), fmt.Sprintf(format, args...))
+	}
+}
+
+// rewriteFunc rewrite the range-over-func loops of the function body, the
+// nested function literals are rewritten by the caller.
+func (r *rangeFuncRewriter) rewriteFunc(sig *types.Signature, body *ast.BlockStmt) {
+	r.rewriteList(sig, body.List)
+}
+
+func (r *rangeFuncRewriter) rewriteList(sig *types.Signature, list []ast.Stmt) {
+	for i, s := range list {
+		list[i] = r.rewriteStmt(sig, s)
+	}
+}
+
+func (r *rangeFuncRewriter) rewriteStmt(sig *types.Signature, s ast.Stmt) ast.Stmt {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		r.rewriteList(sig, s.List)
+	case *ast.IfStmt:
+		r.rewriteList(sig, s.Body.List)
+		if s.Else != nil {
+			s.Else = r.rewriteStmt(sig, s.Else)
+		}
+	case *ast.ForStmt:
+		r.rewriteList(sig, s.Body.List)
+	case *ast.SwitchStmt:
+		r.rewriteList(sig, s.Body.List)
+	case *ast.TypeSwitchStmt:
+		r.rewriteList(sig, s.Body.List)
+	case *ast.SelectStmt:
+		r.rewriteList(sig, s.Body.List)
+	case *ast.CaseClause:
+		r.rewriteList(sig, s.Body)
+	case *ast.CommClause:
+		r.rewriteList(sig, s.Body)
+	case *ast.LabeledStmt:
+		if rs, ok := s.Stmt.(*ast.RangeStmt); ok && r.isRangeFunc(rs) {
+			r.rewriteList(sig, rs.Body.List)
+			return r.rewriteRange(sig, s.Label.Name, rs)
+		}
+		s.Stmt = r.rewriteStmt(sig, s.Stmt)
+	case *ast.RangeStmt:
+		r.rewriteList(sig, s.Body.List)
+		if r.isRangeFunc(s) {
+			return r.rewriteRange(sig, "", s)
+		}
+	}
+	return s
+}
+
+func (r *rangeFuncRewriter) isRangeFunc(rs *ast.RangeStmt) bool {
+	typ := r.info.TypeOf(rs.X)
+	if typ == nil {
+		return false
+	}
+	_, ok := typ.Underlying().(*types.Signature)
+	return ok
+}
+
+func (r *rangeFuncRewriter) rewriteRange(sig *types.Signature, label string, rs *ast.RangeStmt) ast.Stmt {
+	if r.err != nil {
+		return rs
+	}
+	r.index++
+	loop := &rangeFuncLoop{
+		index:  r.index,
+		label:  label,
+		sig:    sig,
+		labels: make(map[string]bool),
+	}
+	collectLabels(rs.Body.List, loop.labels)
+	r.rewriteBody(loop, rs.Body.List, 0, 0)
+	if r.err != nil {
+		return rs
+	}
+	ctl := ast.NewIdent(r.name("ctl", loop.index))
+	done := ast.NewIdent(r.name("done", loop.index))
+	var stmts []ast.Stmt
+	if loop.ctl {
+		stmts = append(stmts, varDecl(ctl.Name, ast.NewIdent("int")))
+	}
+	stmts = append(stmts, varDecl(done.Name, ast.NewIdent("bool")))
+	if loop.results {
+		for i := 0; i < sig.Results().Len(); i++ {
+			stmts = append(stmts, varDecl(r.resultName(loop, i), r.typeExpr(sig.Results().At(i).Type())))
+		}
+	}
+
+	// yield func
+	yield := rangeFuncYield(r.info.TypeOf(rs.X))
+	var params []*ast.Field
+	var assign []ast.Expr
+	var lhs []ast.Expr
+	for i, v := range []ast.Expr{rs.Key, rs.Value} {
+		if i >= yield.Params().Len() {
+			break
+		}
+		name := "_"
+		if v != nil {
+			if id, ok := v.(*ast.Ident); ok && rs.Tok == token.DEFINE {
+				name = id.Name
+			} else if !isBlank(v) {
+				name = r.name("p"+strconv.Itoa(i), loop.index)
+				lhs = append(lhs, v)
+				assign = append(assign, ast.NewIdent(name))
+			}
+		}
+		params = append(params, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  r.typeExpr(yield.Params().At(i).Type()),
+		})
+	}
+	for i := len(params); i < yield.Params().Len(); i++ {
+		params = append(params, &ast.Field{
+			Names: []*ast.Ident{ast.NewIdent("_")},
+			Type:  r.typeExpr(yield.Params().At(i).Type()),
+		})
+	}
+	var body []ast.Stmt
+	body = append(body, &ast.IfStmt{
+		Cond: ast.NewIdent(done.Name),
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  ast.NewIdent("panic"),
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(errRangeFuncContinued)}},
+			}},
+		}},
+	})
+	if len(lhs) > 0 {
+		body = append(body, &ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: assign})
+	}
+	body = append(body, rs.Body.List...)
+	body = append(body, &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("true")}})
+	lit := &ast.FuncLit{
+		Type: &ast.FuncType{
+			Func:    rs.For,
+			Params:  &ast.FieldList{List: params},
+			Results: &ast.FieldList{List: []*ast.Field{{Type: ast.NewIdent("bool")}}},
+		},
+		Body: &ast.BlockStmt{List: body},
+	}
+	stmts = append(stmts, &ast.ExprStmt{X: &ast.CallExpr{Fun: rs.X, Args: []ast.Expr{lit}}})
+	stmts = append(stmts, &ast.AssignStmt{
+		Lhs: []ast.Expr{ast.NewIdent(done.Name)},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{ast.NewIdent("true")},
+	})
+
+	// control flow after iterator call
+	if loop.ctl {
+		ctlIf := func(code int, s ast.Stmt) {
+			stmts = append(stmts, &ast.IfStmt{
+				Cond: &ast.BinaryExpr{
+					X:  ast.NewIdent(ctl.Name),
+					Op: token.EQL,
+					Y:  &ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(code)},
+				},
+				Body: &ast.BlockStmt{List: []ast.Stmt{s}},
+			})
+		}
+		if loop.bare {
+			ctlIf(ctlReturn, &ast.ReturnStmt{})
+		}
+		if loop.results {
+			var results []ast.Expr
+			for i := 0; i < sig.Results().Len(); i++ {
+				results = append(results, ast.NewIdent(r.resultName(loop, i)))
+			}
+			ctlIf(ctlReturnValue, &ast.ReturnStmt{Results: results})
+		}
+		for i, b := range loop.targets {
+			ctlIf(ctlLabel+i, &ast.BranchStmt{Tok: b.Tok, Label: ast.NewIdent(b.Label.Name)})
+		}
+	}
+	return &ast.BlockStmt{Lbrace: rs.For, List: stmts, Rbrace: rs.End()}
+}
+
+// rewriteBody convert the control flow of loop body to the yield func.
+// brk and cont is the depth of nested statements handle break and continue.
+func (r *rangeFuncRewriter) rewriteBody(loop *rangeFuncLoop, list []ast.Stmt, brk, cont int) {
+	for i, s := range list {
+		list[i] = r.rewriteBodyStmt(loop, s, brk, cont)
+	}
+}
+
+func (r *rangeFuncRewriter) rewriteBodyStmt(loop *rangeFuncLoop, s ast.Stmt, brk, cont int) ast.Stmt {
+	switch s := s.(type) {
+	case *ast.BlockStmt:
+		r.rewriteBody(loop, s.List, brk, cont)
+	case *ast.IfStmt:
+		r.rewriteBody(loop, s.Body.List, brk, cont)
+		if s.Else != nil {
+			s.Else = r.rewriteBodyStmt(loop, s.Else, brk, cont)
+		}
+	case *ast.ForStmt:
+		r.rewriteBody(loop, s.Body.List, brk+1, cont+1)
+	case *ast.RangeStmt:
+		r.rewriteBody(loop, s.Body.List, brk+1, cont+1)
+	case *ast.SwitchStmt:
+		r.rewriteBody(loop, s.Body.List, brk+1, cont)
+	case *ast.TypeSwitchStmt:
+		r.rewriteBody(loop, s.Body.List, brk+1, cont)
+	case *ast.SelectStmt:
+		r.rewriteBody(loop, s.Body.List, brk+1, cont)
+	case *ast.CaseClause:
+		r.rewriteBody(loop, s.Body, brk, cont)
+	case *ast.CommClause:
+		r.rewriteBody(loop, s.Body, brk, cont)
+	case *ast.LabeledStmt:
+		s.Stmt = r.rewriteBodyStmt(loop, s.Stmt, brk, cont)
+	case *ast.DeferStmt:
+		r.errorf(s.Pos(), "defer in range-over-func loop body is not supported")
+	case *ast.BranchStmt:
+		return r.rewriteBranch(loop, s, brk, cont)
+	case *ast.ReturnStmt:
+		return r.rewriteReturn(loop, s)
+	}
+	return s
+}
+
+func (r *rangeFuncRewriter) rewriteBranch(loop *rangeFuncLoop, s *ast.BranchStmt, brk, cont int) ast.Stmt {
+	switch s.Tok {
+	case token.BREAK, token.CONTINUE:
+		var self bool
+		if s.Label == nil {
+			if s.Tok == token.BREAK {
+				self = brk == 0
+			} else {
+				self = cont == 0
+			}
+			if !self {
+				return s
+			}
+		} else if loop.labels[s.Label.Name] {
+			return s
+		} else {
+			self = s.Label.Name == loop.label
+		}
+		if self {
+			if s.Tok == token.BREAK {
+				return r.yieldReturn(loop)
+			}
+			return &ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("true")}}
+		}
+		// break/continue outer label
+		code := -1
+		for i, b := range loop.targets {
+			if b.Tok == s.Tok && b.Label.Name == s.Label.Name {
+				code = ctlLabel + i
+				break
+			}
+		}
+		if code == -1 {
+			code = ctlLabel + len(loop.targets)
+			loop.targets = append(loop.targets, s)
+		}
+		return r.setCtl(loop, code, nil)
+	case token.GOTO:
+		if !loop.labels[s.Label.Name] {
+			r.errorf(s.Pos(), "goto %v out of range-over-func loop body is not supported", s.Label.Name)
+		}
+	}
+	return s
+}
+
+func (r *rangeFuncRewriter) rewriteReturn(loop *rangeFuncLoop, s *ast.ReturnStmt) ast.Stmt {
+	if len(s.Results) == 0 {
+		loop.bare = true
+		return r.setCtl(loop, ctlReturn, nil)
+	}
+	loop.results = true
+	var lhs []ast.Expr
+	for i := 0; i < loop.sig.Results().Len(); i++ {
+		lhs = append(lhs, ast.NewIdent(r.resultName(loop, i)))
+	}
+	return r.setCtl(loop, ctlReturnValue, &ast.AssignStmt{Lhs: lhs, Tok: token.ASSIGN, Rhs: s.Results})
+}
+
+// setCtl set the control code of loop and stop iteration.
+func (r *rangeFuncRewriter) setCtl(loop *rangeFuncLoop, code int, pre ast.Stmt) ast.Stmt {
+	loop.ctl = true
+	var list []ast.Stmt
+	if pre != nil {
+		list = append(list, pre)
+	}
+	list = append(list, &ast.AssignStmt{
+		Lhs: []ast.Expr{ast.NewIdent(r.name("ctl", loop.index))},
+		Tok: token.ASSIGN,
+		Rhs: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: strconv.Itoa(code)}},
+	})
+	list = append(list, r.yieldReturn(loop).List...)
+	return &ast.BlockStmt{List: list}
+}
+
+// yieldReturn return false from the yield func and mark the loop done.
+func (r *rangeFuncRewriter) yieldReturn(loop *rangeFuncLoop) *ast.BlockStmt {
+	return &ast.BlockStmt{List: []ast.Stmt{
+		&ast.AssignStmt{
+			Lhs: []ast.Expr{ast.NewIdent(r.name("done", loop.index))},
+			Tok: token.ASSIGN,
+			Rhs: []ast.Expr{ast.NewIdent("true")},
+		},
+		&ast.ReturnStmt{Results: []ast.Expr{ast.NewIdent("false")}},
+	}}
+}
+
+func (r *rangeFuncRewriter) name(kind string, index int) string {
+	return "__igop_range" + kind + strconv.Itoa(index)
+}
+
+func (r *rangeFuncRewriter) resultName(loop *rangeFuncLoop, i int) string {
+	return r.name("r"+strconv.Itoa(i)+"_", loop.index)
+}
+
+// typeExpr returns the type expression of typ in file scope.
+func (r *rangeFuncRewriter) typeExpr(typ types.Type) ast.Expr {
+	s := types.TypeString(typ, func(pkg *types.Package) string {
+		if pkg == r.pkg {
+			return ""
+		}
+		return r.importName(pkg)
+	})
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		r.errorf(r.file.Package, "range-over-func type %v: %v", s, err)
+		return ast.NewIdent("_")
+	}
+	resetPos(expr)
+	return expr
+}
+
+// importName returns the name of the package imported by file, the import
+// is added if not found.
+func (r *rangeFuncRewriter) importName(pkg *types.Package) string {
+	for _, spec := range r.file.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == pkg.Path() {
+			if spec.Name == nil {
+				return pkg.Name()
+			}
+			switch spec.Name.Name {
+			case "_":
+				continue
+			case ".":
+				return ""
+			}
+			return spec.Name.Name
+		}
+	}
+	if name, ok := r.imports[pkg.Path()]; ok {
+		return name
+	}
+	if r.imports == nil {
+		r.imports = make(map[string]string)
+	}
+	name := "__igop_rangeimport" + strconv.Itoa(len(r.imports))
+	r.imports[pkg.Path()] = name
+	spec := &ast.ImportSpec{
+		Name: ast.NewIdent(name),
+		Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(pkg.Path())},
+	}
+	r.file.Imports = append(r.file.Imports, spec)
+	r.file.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{spec}}}, r.file.Decls...)
+	return name
+}
+
+func rangeFuncYield(typ types.Type) *types.Signature {
+	sig := typ.Underlying().(*types.Signature)
+	return sig.Params().At(0).Type().Underlying().(*types.Signature)
+}
+
+func varDecl(name string, typ ast.Expr) ast.Stmt {
+	return &ast.DeclStmt{Decl: &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{ast.NewIdent(name)},
+			Type:  typ,
+		}},
+	}}
+}
+
+func isBlank(e ast.Expr) bool {
+	id, ok := e.(*ast.Ident)
+	return ok && id.Name == "_"
+}
+
+// collectLabels collect the labels defined in list, exclude func literals.
+func collectLabels(list []ast.Stmt, labels map[string]bool) {
+	for _, s := range list {
+		ast.Inspect(s, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.LabeledStmt:
+				labels[n.Label.Name] = true
+			}
+			return true
+		})
+	}
+}
+
+var typTokenPos = reflect.TypeOf(token.NoPos)
+
+// resetPos reset the positions of node parsed from other file set, the
+// generated nodes have no position.
+func resetPos(node ast.Node) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n == nil {
+			return false
+		}
+		v := reflect.ValueOf(n)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			return true
+		}
+		v = v.Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.Type() == typTokenPos && f.CanSet() {
+				f.SetInt(0)
+			}
+		}
+		return true
+	})
+}
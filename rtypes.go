@@ -61,6 +61,7 @@ type TypesLoader struct {
 	curpkg    *Package
 	packages  map[string]*types.Package
 	installed map[string]*Package
+	runtimes  map[string]*Package
 	pkgloads  map[string]func() error
 	rcache    map[reflect.Type]types.Type
 	mode      Mode
@@ -71,6 +72,7 @@ func NewTypesLoader(ctx *Context, mode Mode) Loader {
 	r := &TypesLoader{
 		packages:  make(map[string]*types.Package),
 		installed: make(map[string]*Package),
+		runtimes:  make(map[string]*Package),
 		pkgloads:  make(map[string]func() error),
 		rcache:    make(map[reflect.Type]types.Type),
 		tcache:    &typeutil.Map{},
@@ -146,19 +148,39 @@ func (r *TypesLoader) LookupTypes(typ reflect.Type) (types.Type, bool) {
 	return t, ok
 }
 
+// RegisterPackage register the package to the loader only, it takes
+// precedence over the package registered by RegisterPackage.
+func (r *TypesLoader) RegisterPackage(pkg *Package) error {
+	if _, ok := r.packages[pkg.Path]; ok {
+		return fmt.Errorf("register package %v: already imported", pkg.Path)
+	}
+	r.runtimes[pkg.Path] = pkg
+	return nil
+}
+
+func (r *TypesLoader) lookupRegister(path string) (pkg *Package, ok bool) {
+	if pkg, ok = r.runtimes[path]; ok {
+		return
+	}
+	pkg, ok = registerPkgs[path]
+	return
+}
+
 func (r *TypesLoader) Import(path string) (*types.Package, error) {
 	if p, ok := r.packages[path]; ok {
 		if !p.Complete() {
 			if load, ok := r.pkgloads[path]; ok {
 				load()
+				// load may replace the package, see rewriteRangeFunc
+				p = r.packages[path]
 			}
-			if pkg, ok := registerPkgs[path]; ok {
+			if pkg, ok := r.lookupRegister(path); ok {
 				r.installed[path] = pkg
 			}
 		}
 		return p, nil
 	}
-	pkg, ok := registerPkgs[path]
+	pkg, ok := r.lookupRegister(path)
 	if !ok {
 		return nil, fmt.Errorf("not found package %v", path)
 	}
@@ -176,11 +198,11 @@ func (r *TypesLoader) Import(path string) (*types.Package, error) {
 				return nil, err
 			}
 		}
+		tp.Register = true
 		if err := tp.Load(); err != nil {
 			log.Printf("igop warning: load pkg %v source error: %v\n", pkg.Path, err)
 			return nil, err
 		}
-		tp.Register = true
 		r.packages[path] = tp.Package
 		r.installed[path] = pkg
 		return tp.Package, nil
@@ -21,7 +21,6 @@ import (
 	"fmt"
 	"go/token"
 	"go/types"
-	"os"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -46,13 +45,18 @@ func init() {
 	})
 	RegisterExternal("runtime.Goexit", func(fr *frame) {
 		interp := fr.interp
-		// main goroutine use panic
-		if goroutineID() == interp.mainid {
+		if id := goroutineID(); id == interp.mainid {
 			atomic.StoreInt32(&interp.goexited, 1)
-			panic(goexitPanic(0))
-		} else {
+		} else if _, ok := interp.goids.Load(id); !ok {
+			// host goroutine, e.g. the callback of time.AfterFunc
 			runtime.Goexit()
 		}
+		// use panic to run deferred calls, goexitPanic cannot be recovered.
+		panic(goexitPanic(0))
+	})
+	RegisterExternal("runtime.NumGoroutine", func(fr *frame) int {
+		// interpreted goroutines, exclude the host and interpreter internals
+		return int(atomic.LoadInt32(&fr.interp.goroutines))
 	})
 	RegisterExternal("runtime.Caller", runtimeCaller)
 	RegisterExternal("runtime.FuncForPC", runtimeFuncForPC)
@@ -300,7 +304,7 @@ func runtimeStack(fr *frame, buf []byte, all bool) int {
 
 // PrintStack prints to standard error the stack trace returned by runtime.Stack.
 func debugPrintStack(fr *frame) {
-	os.Stderr.Write(debugStack(fr))
+	fr.interp.ctx.writeErrOutput(debugStack(fr))
 }
 
 // Stack returns a formatted stack trace of the goroutine that calls it.
@@ -1016,3 +1016,110 @@ func main() {
 		t.Fatal(err)
 	}
 }
+
+func TestGoexitAfterRecover(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+func main() {
+	var wg sync.WaitGroup
+	var result []string
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			result = append(result, "last")
+		}()
+		defer runtime.Goexit()
+		defer func() {
+			if r := recover(); r != "error1" {
+				panic(fmt.Errorf("recover %v", r))
+			}
+			result = append(result, "recover1")
+		}()
+		panic("error1")
+	}()
+	wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				panic(fmt.Errorf("recover goexit %v", r))
+			}
+			result = append(result, "goexit")
+		}()
+		defer func() {
+			if r := recover(); r != "error2" {
+				panic(fmt.Errorf("recover %v", r))
+			}
+			result = append(result, "recover2")
+			runtime.Goexit()
+			panic("unreachable")
+		}()
+		panic("error2")
+	}()
+	wg.Wait()
+	wg.Add(1)
+	time.AfterFunc(0, func() {
+		wg.Done()
+		runtime.Goexit()
+	})
+	wg.Wait()
+	if v := fmt.Sprint(result); v != "[recover1 last recover2 goexit]" {
+		panic(v)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNumGoroutine(t *testing.T) {
+	src := `package main
+
+import (
+	"runtime"
+	"time"
+)
+
+func main() {
+	if n := runtime.NumGoroutine(); n != 1 {
+		panic(n)
+	}
+	start := make(chan bool)
+	done := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() {
+			start <- true
+			<-done
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		<-start
+	}
+	if n := runtime.NumGoroutine(); n != 4 {
+		panic(n)
+	}
+	close(done)
+	for i := 0; i < 100 && runtime.NumGoroutine() != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n != 1 {
+		panic(n)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
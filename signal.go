@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2022 The GoPlus Authors (goplus.org). All rights reserved.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package igop
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// With EnableVirtualSignal the os/signal package of the interpreted program
+// does not register on the host signal delivery, the signals are sent to the
+// interpreted program by Interp.SendSignal only.
+
+func init() {
+	RegisterExternal("os/signal.Notify", func(fr *frame, c chan<- os.Signal, sig ...os.Signal) {
+		if !fr.interp.virtualSignal() {
+			signal.Notify(c, sig...)
+			return
+		}
+		if c == nil {
+			panic("os/signal: Notify using nil channel")
+		}
+		fr.interp.signals.notify(c, sig)
+	})
+	RegisterExternal("os/signal.Stop", func(fr *frame, c chan<- os.Signal) {
+		if !fr.interp.virtualSignal() {
+			signal.Stop(c)
+			return
+		}
+		fr.interp.signals.stop(c)
+	})
+	RegisterExternal("os/signal.Reset", func(fr *frame, sig ...os.Signal) {
+		if !fr.interp.virtualSignal() {
+			signal.Reset(sig...)
+			return
+		}
+		fr.interp.signals.reset(sig, false)
+	})
+	RegisterExternal("os/signal.Ignore", func(fr *frame, sig ...os.Signal) {
+		if !fr.interp.virtualSignal() {
+			signal.Ignore(sig...)
+			return
+		}
+		fr.interp.signals.reset(sig, true)
+	})
+	RegisterExternal("os/signal.Ignored", func(fr *frame, sig os.Signal) bool {
+		if !fr.interp.virtualSignal() {
+			return signal.Ignored(sig)
+		}
+		return fr.interp.signals.ignored(sig)
+	})
+	RegisterExternal("os/signal.NotifyContext", func(fr *frame, parent context.Context, sig ...os.Signal) (context.Context, context.CancelFunc) {
+		i := fr.interp
+		if !i.virtualSignal() {
+			return signal.NotifyContext(parent, sig...)
+		}
+		ctx, cancel := context.WithCancel(parent)
+		c := make(chan os.Signal, 1)
+		i.signals.notify(c, sig)
+		if ctx.Err() == nil {
+			go func() {
+				select {
+				case <-c:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+		}
+		return ctx, func() {
+			cancel()
+			i.signals.stop(c)
+		}
+	})
+}
+
+func (i *Interp) virtualSignal() bool {
+	return i.ctx.Mode&EnableVirtualSignal != 0
+}
+
+// SendSignal delivers sig to the channels of the interpreted program
+// registered by signal.Notify, it needs EnableVirtualSignal. Like the host
+// delivery, the send does not block and the signal is dropped for the
+// channels not ready.
+func (i *Interp) SendSignal(sig os.Signal) {
+	i.signals.send(sig)
+}
+
+// signalTable is the virtual signal delivery of an interp.
+type signalTable struct {
+	mu       sync.Mutex
+	handlers map[chan<- os.Signal]*signalMask
+	ignore   signalMask
+}
+
+// signalMask is a set of signals. If all is set, sigs are the signals
+// excluded from all signals.
+type signalMask struct {
+	all  bool
+	sigs map[os.Signal]bool
+}
+
+func (m *signalMask) has(sig os.Signal) bool {
+	return m.all != m.sigs[sig]
+}
+
+func (m *signalMask) add(sigs []os.Signal) {
+	if len(sigs) == 0 {
+		m.all, m.sigs = true, nil
+		return
+	}
+	m.update(sigs, !m.all)
+}
+
+// remove reports whether the mask is empty after sigs removed.
+func (m *signalMask) remove(sigs []os.Signal) bool {
+	if len(sigs) == 0 {
+		m.all, m.sigs = false, nil
+		return true
+	}
+	m.update(sigs, m.all)
+	return !m.all && len(m.sigs) == 0
+}
+
+func (m *signalMask) update(sigs []os.Signal, set bool) {
+	if m.sigs == nil {
+		m.sigs = make(map[os.Signal]bool)
+	}
+	for _, sig := range sigs {
+		if set {
+			m.sigs[sig] = true
+		} else {
+			delete(m.sigs, sig)
+		}
+	}
+}
+
+func (t *signalTable) notify(c chan<- os.Signal, sigs []os.Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.handlers == nil {
+		t.handlers = make(map[chan<- os.Signal]*signalMask)
+	}
+	m, ok := t.handlers[c]
+	if !ok {
+		m = &signalMask{}
+		t.handlers[c] = m
+	}
+	m.add(sigs)
+	t.ignore.remove(sigs)
+}
+
+func (t *signalTable) stop(c chan<- os.Signal) {
+	t.mu.Lock()
+	delete(t.handlers, c)
+	t.mu.Unlock()
+}
+
+// reset undoes the effect of prior notify calls for sigs, and marks sigs
+// ignored if ignore is true.
+func (t *signalTable) reset(sigs []os.Signal, ignore bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for c, m := range t.handlers {
+		if m.remove(sigs) {
+			delete(t.handlers, c)
+		}
+	}
+	if ignore {
+		t.ignore.add(sigs)
+	} else {
+		t.ignore.remove(sigs)
+	}
+}
+
+func (t *signalTable) ignored(sig os.Signal) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ignore.has(sig)
+}
+
+func (t *signalTable) send(sig os.Signal) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ignore.has(sig) {
+		return
+	}
+	for c, m := range t.handlers {
+		if m.has(sig) {
+			select {
+			case c <- sig:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+// +build !linux
+
+package goos
+
+const Info = "other"
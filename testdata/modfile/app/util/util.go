@@ -0,0 +1,7 @@
+package util
+
+import "example.com/lib"
+
+func Hello(name string) string {
+	return lib.Greeting + ", " + name
+}
@@ -0,0 +1,5 @@
+package testrun
+
+func Add(a, b int) int {
+	return a + b
+}
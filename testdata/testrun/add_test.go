@@ -0,0 +1,13 @@
+package testrun
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if v := Add(100, 200); v != 300 {
+		t.Fatalf("Add(100, 200) = %v", v)
+	}
+}
+
+func TestFail(t *testing.T) {
+	t.Fatal("must skip by -test.run")
+}
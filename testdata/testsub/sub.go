@@ -0,0 +1,5 @@
+package testsub
+
+func Add(a, b int) int {
+	return a + b
+}
@@ -0,0 +1,85 @@
+package testsub
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (r *recorder) add(s string) {
+	r.mu.Lock()
+	r.logs = append(r.logs, s)
+	r.mu.Unlock()
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.logs, ",")
+}
+
+func checkAdd(t *testing.T, a, b, want int) {
+	t.Helper()
+	if v := Add(a, b); v != want {
+		t.Fatalf("Add(%v, %v) = %v, want %v", a, b, v, want)
+	}
+}
+
+func TestSubtests(t *testing.T) {
+	var r recorder
+	t.Cleanup(func() {
+		// parallel subtests finished before the parent cleanup
+		if s := r.String(); s != "run,cleanup1,p1,p2,cleanup2,cleanup0" && s != "run,cleanup1,p2,p1,cleanup2,cleanup0" {
+			t.Errorf("bad order %q", s)
+		}
+	})
+	t.Cleanup(func() {
+		r.add("cleanup0")
+	})
+	ok := t.Run("add", func(t *testing.T) {
+		t.Cleanup(func() {
+			r.add("cleanup1")
+		})
+		checkAdd(t, 1, 2, 3)
+		r.add("run")
+	})
+	if !ok {
+		t.Fatal("subtest add must pass")
+	}
+	t.Run("group", func(t *testing.T) {
+		t.Cleanup(func() {
+			r.add("cleanup2")
+		})
+		for _, name := range []string{"p1", "p2"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				checkAdd(t, 100, 200, 300)
+				r.add(name)
+			})
+		}
+	})
+	if name := t.Name(); name != "TestSubtests" {
+		t.Fatalf("bad name %v", name)
+	}
+}
+
+func TestSubFatal(t *testing.T) {
+	var r recorder
+	ok := t.Run("fatal", func(t *testing.T) {
+		defer r.add("defer")
+		checkAdd(t, 1, 2, 4)
+		r.add("unreachable")
+	})
+	if ok {
+		t.Error("subtest fatal must fail")
+	}
+	if s := r.String(); s != "defer" {
+		t.Errorf("bad logs %q", s)
+	}
+}
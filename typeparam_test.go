@@ -22,6 +22,7 @@ package igop_test
 import (
 	"bytes"
 	"fmt"
+	"go/types"
 	"runtime"
 	"testing"
 
@@ -29,6 +30,7 @@ import (
 	_ "github.com/goplus/igop/pkg/go/token"
 	_ "github.com/goplus/igop/pkg/path/filepath"
 	_ "github.com/goplus/igop/pkg/reflect"
+	_ "github.com/goplus/igop/pkg/strconv"
 	_ "github.com/goplus/igop/pkg/sync/atomic"
 )
 
@@ -343,3 +345,127 @@ func main() {
 		t.Fatal(err)
 	}
 }
+
+func TestTypeParamInference(t *testing.T) {
+	src := `package main
+
+import "strconv"
+
+func Map[T, U any](s []T, f func(T) U) []U {
+	r := make([]U, 0, len(s))
+	for _, v := range s {
+		r = append(r, f(v))
+	}
+	return r
+}
+
+func Reduce[T, A any](s []T, init A, f func(A, T) A) A {
+	acc := init
+	for _, v := range s {
+		acc = f(acc, v)
+	}
+	return acc
+}
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+func Sum[T Number](s ...T) (n T) {
+	for _, v := range s {
+		n += v
+	}
+	return
+}
+
+type MyInt int
+
+func main() {
+	s := Map([]int{1, 2, 3}, strconv.Itoa)
+	if len(s) != 3 || s[0] != "1" || s[2] != "3" {
+		panic(s)
+	}
+	n := Map(s, func(v string) int {
+		i, _ := strconv.Atoi(v)
+		return i * 10
+	})
+	if r := Reduce(n, "", func(acc string, v int) string {
+		return acc + strconv.Itoa(v)
+	}); r != "102030" {
+		panic(r)
+	}
+	if v := Sum(1.5, 2.5); v != 4 {
+		panic(v)
+	}
+	if v := Sum(MyInt(1), 2, 3); v != 6 {
+		panic(v)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeParamNamedIntQuo(t *testing.T) {
+	src := `package main
+
+type Int int
+type Int64 int64
+
+func quo[T ~int | ~int8 | ~int64](a, b T) (T, T) {
+	return a / b, a % b
+}
+
+func main() {
+	if q, r := quo(Int(-7), Int(2)); q != -3 || r != -1 {
+		panic(q)
+	}
+	if q, r := quo(Int64(-7), Int64(-2)); q != 3 || r != -1 {
+		panic(q)
+	}
+}
+`
+	_, err := igop.RunFile("main.go", src, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTypeParamSetSizes(t *testing.T) {
+	src := `package main
+
+import "unsafe"
+
+type S struct {
+	a int8
+	b int64
+	c int32
+}
+
+func sizeof[T any](v T) uintptr {
+	return unsafe.Sizeof(v)
+}
+
+func alignof[T any](v T) uintptr {
+	return unsafe.Alignof(v)
+}
+
+func main() {
+	var s S
+	if n := sizeof(s); n != 16 {
+		panic(n)
+	}
+	if n := alignof(s.b); n != 4 {
+		panic(n)
+	}
+}
+`
+	ctx := igop.NewContext(0)
+	ctx.SetSizes(&types.StdSizes{WordSize: 4, MaxAlign: 4})
+	_, err := ctx.RunFile("main.go", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
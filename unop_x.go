@@ -82,7 +82,7 @@ func makeUnOpARROW(pfn *function, instr *ssa.UnOp) func(fr *frame) {
 		x := reflect.ValueOf(vx)
 		if instr.CommaOk {
 			return func(fr *frame) {
-				v, ok := x.Recv()
+				v, ok := fr.interp.chanRecv(x)
 				if !ok {
 					v = reflect.New(typ).Elem()
 				}
@@ -90,7 +90,7 @@ func makeUnOpARROW(pfn *function, instr *ssa.UnOp) func(fr *frame) {
 			}
 		}
 		return func(fr *frame) {
-			v, ok := x.Recv()
+			v, ok := fr.interp.chanRecv(x)
 			if !ok {
 				v = reflect.New(typ).Elem()
 			}
@@ -100,7 +100,7 @@ func makeUnOpARROW(pfn *function, instr *ssa.UnOp) func(fr *frame) {
 	if instr.CommaOk {
 		return func(fr *frame) {
 			x := reflect.ValueOf(fr.reg(ix))
-			v, ok := x.Recv()
+			v, ok := fr.interp.chanRecv(x)
 			if !ok {
 				v = reflect.New(typ).Elem()
 			}
@@ -109,7 +109,7 @@ func makeUnOpARROW(pfn *function, instr *ssa.UnOp) func(fr *frame) {
 	}
 	return func(fr *frame) {
 		x := reflect.ValueOf(fr.reg(ix))
-		v, ok := x.Recv()
+		v, ok := fr.interp.chanRecv(x)
 		if !ok {
 			v = reflect.New(typ).Elem()
 		}
@@ -56,6 +56,33 @@ func checkPackages(intp *Interp, pkgs []*ssa.Package) (err error) {
 	return
 }
 
+// checkFunction visits fn of pkg that created after the interp, the
+// functions already visited by interp are skipped.
+func checkFunction(intp *Interp, pkg *ssa.Package, fn *ssa.Function) (err error) {
+	if intp.ctx.Mode&DisableRecover == 0 {
+		defer func() {
+			if v := recover(); v != nil {
+				err = v.(error)
+			}
+		}()
+	}
+	visit := visitor{
+		intp: intp,
+		prog: intp.mainpkg.Prog,
+		pkgs: map[*ssa.Package]bool{pkg: true},
+		seen: make(map[*ssa.Function]bool),
+		base: fnBase,
+	}
+	for f, pfn := range intp.funcs {
+		visit.seen[f] = true
+		if base := pfn.base + len(pfn.ssaInstrs) + 2; base > visit.base {
+			visit.base = base
+		}
+	}
+	visit.function(fn)
+	return
+}
+
 type visitor struct {
 	intp *Interp
 	prog *ssa.Program
@@ -316,6 +343,23 @@ func (visit *visitor) function(fn *ssa.Function) {
 			if ifn == nil {
 				continue
 			}
+			if visit.intp.ctx.intSize == 32 {
+				ifn = makeIntSize32Instr(pfn, instr, ifn)
+			}
+			if visit.intp.ctx.Mode&EnableAllocStats != 0 {
+				ifn = makeAllocStatsInstr(pfn, instr, ifn)
+			}
+			if visit.intp.ctx.chanHook != nil {
+				ifn = makeChanHookInstr(pfn, instr, ifn)
+			}
+			if coverFn := visit.intp.ctx.coverFunc; coverFn != nil {
+				ofn := ifn
+				pc := len(pfn.Instrs) + index
+				ifn = func(fr *frame) {
+					coverFn(fr.pfn, pc)
+					ofn(fr)
+				}
+			}
 			if visit.intp.ctx.evalMode && fn.String() == "main.init" {
 				if visit.intp.ctx.evalInit == nil {
 					visit.intp.ctx.evalInit = make(map[string]bool)